@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/ledger"
+)
+
+func runApprove(args []string) int {
+	fs := newFlagSet("approve", "approve [flags] [SYMBOL...]",
+		"Move the outliers quarantined by fetch into the price DB, for all symbols or\n"+
+			"only the given ones. With -list they are printed instead, and with -discard\n"+
+			"they are removed from the quarantine file without being written.")
+	g := addGlobals(fs)
+	list := fs.Bool("list", false, "print the quarantined prices and exit")
+	discard := fs.Bool("discard", false, "remove the quarantined prices instead of writing them")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	cfg, err := g.loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "calais: failed to load config: %v\n", err)
+		return exitError
+	}
+	// Quarantined prices are written in ledger.timezone and reach the store
+	// at the time they were fetched.
+	loc, _ := cfg.Ledger.Location()
+	path := cfg.Ledger.Quarantine
+	entries, _, err := ledger.ReadFileIn(path, loc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+
+	symbols := map[string]bool{}
+	for _, s := range fs.Args() {
+		symbols[s] = true
+	}
+	selected := map[int]bool{}
+	var matched []ledger.Entry
+	for _, e := range entries {
+		if len(symbols) == 0 || symbols[e.Symbol] {
+			selected[e.Line] = true
+			matched = append(matched, e)
+		}
+	}
+	if *list {
+		for _, e := range matched {
+			fmt.Println(e.Text)
+		}
+		return exitOK
+	}
+	if len(matched) == 0 {
+		fmt.Printf("no quarantined prices in %s\n", path)
+		return exitOK
+	}
+
+	if !*discard {
+		if err := cfg.Ledger.CheckWritable(); err != nil {
+			fmt.Fprintln(os.Stderr, "calais:", err)
+			return exitError
+		}
+		var opts []ledger.Option
+		if cfg.Ledger.Dedup {
+			opts = append(opts, ledger.WithDedup())
+		}
+		w := ledger.NewWriter(cfg.Ledger.PriceDB, opts...)
+		for _, e := range matched {
+			if err := w.AppendEntry(e); err != nil {
+				fmt.Fprintln(os.Stderr, "calais:", err)
+				return exitError
+			}
+		}
+		records := make([]doctype.Record, 0, len(matched))
+		for _, e := range matched {
+			records = append(records, entryRecord(e))
+		}
+		if err := storeRecords(cfg, records); err != nil {
+			fmt.Fprintln(os.Stderr, "calais:", err)
+			return exitError
+		}
+	}
+	if _, err := ledger.Rewrite(path, func(e ledger.Entry) bool { return selected[e.Line] }); err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	if *discard {
+		fmt.Printf("discarded %d prices from %s\n", len(matched), path)
+	} else {
+		fmt.Printf("approved %d prices into %s\n", len(matched), cfg.Ledger.PriceDB)
+	}
+	return exitOK
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/internal/runner"
+)
+
+func runBackfill(args []string) int {
+	fs := newFlagSet("backfill", "backfill -from YYYY-MM-DD [flags]",
+		"Fetch the end-of-day prices of a date range and append them to the price DB.\n"+
+			"Only providers with historical data, such as stooq, take part.")
+	g := addGlobals(fs)
+	fromFlag := fs.String("from", "", "first day to fetch (YYYY-MM-DD, required)")
+	toFlag := fs.String("to", "", "last day to fetch (YYYY-MM-DD, default today)")
+	filter := addFilter(fs)
+	dryRun := fs.Bool("dry-run", false, "fetch everything but write nothing; print the changes as a diff (to stderr with -report -)")
+	reportPath := addReport(fs)
+	noCache := addCache(fs)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	if *fromFlag == "" {
+		fmt.Fprintln(os.Stderr, "calais: backfill requires -from")
+		return exitUsage
+	}
+	from, err := parseDate("from", *fromFlag, time.Time{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+	to, err := parseDate("to", *toFlag, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+
+	logger := g.logger(*dryRun || *reportPath == "-")
+	cfg, ok := g.load(logger)
+	if !ok {
+		return exitError
+	}
+
+	sources, errs := runner.Build(cfg, httpClient(cfg, *noCache), logger)
+	for _, err := range errs {
+		logger.Error("failed to create provider", "error", err)
+	}
+	if sources = filter.Sources(sources); len(sources) == 0 {
+		logger.Error("nothing matches the given filters")
+		return exitUsage
+	}
+
+	if !*dryRun {
+		if err := cfg.Ledger.CheckWritable(); err != nil {
+			logger.Error("cannot write the price DB", "error", err)
+			return exitError
+		}
+	}
+	out, err := newOutput(cfg, *dryRun)
+	if err != nil {
+		logger.Error("failed to open the price store", "error", err)
+		return exitError
+	}
+	if *reportPath == "-" {
+		// The report alone goes to stdout.
+		out.diff = os.Stderr
+	}
+	opts, err := out.runOptions(cfg)
+	if err != nil {
+		logger.Error("failed to read the price DB", "error", err)
+		out.finish()
+		return exitError
+	}
+	report := runner.New(sources, nil, out, logger, opts...).Backfill(from, to)
+	if err := out.finish(); err != nil {
+		logger.Error("failed to finish writing", "error", err)
+		return exitError
+	}
+	return finishReport(report, *reportPath, logger)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/internal/runner"
+	"git.sr.ht/~atmosx/calais/pkg/log"
+)
+
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		if len(args) > 0 && args[0] != "-h" && args[0] != "-help" && args[0] != "--help" {
+			fmt.Fprintf(os.Stderr, "calais: unknown config command %q\n\n", args[0])
+		}
+		fmt.Fprintf(os.Stderr, "Usage: calais config validate [flags]\n")
+		return exitUsage
+	}
+	return runConfigValidate(args[1:])
+}
+
+func runConfigValidate(args []string) int {
+	fs := newFlagSet("config validate", "config validate [flags]",
+		"Load and check the configuration and create every provider instance without\n"+
+			"fetching anything. Errors name the file, line and column they refer to.\n"+
+			"Exits with status 1 when the configuration has errors.")
+	g := addGlobals(fs)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	paths, err := g.paths()
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+	// Errors carry the file, line and column they refer to.
+	cfg, err := config.LoadConfig(paths...)
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+
+	logger := log.New(os.Stderr, g.logLevel)
+	_, errs := runner.Build(cfg, http.DefaultClient, logger)
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		return exitError
+	}
+	// Only the commands that write need a writable price DB.
+	if err := cfg.Ledger.CheckWritable(); err != nil {
+		fmt.Printf("warning: %v\n", err)
+	}
+	fmt.Printf("%s: ok\n", strings.Join(paths, ", "))
+	return exitOK
+}
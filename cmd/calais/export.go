@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/ledger"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/store"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/tabular"
+)
+
+func runExport(args []string) int {
+	fs := newFlagSet("export", "export [flags] [SYMBOL...]",
+		"Convert the price DB, or with -store the price history store, into CSV or\n"+
+			"JSON Lines for analysis tools, for all symbols or only the given ones. Each\n"+
+			"price has the columns symbol, date, price, currency, provider and kind. The\n"+
+			"provider of a price is only known in the store.")
+	g := addGlobals(fs)
+	db := fs.String("db", "", "price DB to read (default ledger.price_db from the config)")
+	fromStore := fs.Bool("store", false, "read the price history store configured under store: instead of the price DB")
+	format := fs.String("format", "csv", "output format, csv or jsonl")
+	outPath := fs.String("o", "-", "file to write, replacing it (- for stdout)")
+	dates := fs.String("dates", "", "write dates as timestamp, date, or auto for dates only at midnight (default ledger.dates from the config)")
+	fromFlag := fs.String("from", "", "first day to include (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "last day to include (YYYY-MM-DD)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	from, err := parseDate("from", *fromFlag, time.Time{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+	to, err := parseDate("to", *toFlag, time.Time{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+	switch doctype.Dates(*dates) {
+	case "", doctype.Timestamps, doctype.DatesOnly, doctype.DatesAuto:
+	default:
+		fmt.Fprintf(os.Stderr, "calais: invalid -dates %q, expected timestamp, date or auto\n", *dates)
+		return exitUsage
+	}
+	newWriter := map[string]func(io.Writer, ...tabular.Option) doctype.PriceWriter{
+		"csv":   func(w io.Writer, opts ...tabular.Option) doctype.PriceWriter { return tabular.NewCSV(w, opts...) },
+		"jsonl": func(w io.Writer, opts ...tabular.Option) doctype.PriceWriter { return tabular.NewJSONL(w, opts...) },
+	}[*format]
+	if newWriter == nil {
+		fmt.Fprintf(os.Stderr, "calais: invalid -format %q, expected csv or jsonl\n", *format)
+		return exitUsage
+	}
+
+	// Times are read and written in ledger.timezone, as in the price DB.
+	// Without a configuration, a price DB given with -db is read in the
+	// local time zone, as ledger reads it.
+	cfg, err := g.loadConfig()
+	if err != nil && (*fromStore || *db == "") {
+		fmt.Fprintf(os.Stderr, "calais: failed to load config: %v\n", err)
+		return exitError
+	}
+	var (
+		loc    *time.Location
+		policy = doctype.Dates(*dates)
+	)
+	if cfg != nil {
+		loc, _ = cfg.Ledger.Location()
+		if policy == "" {
+			policy = cfg.Ledger.Dates
+		}
+	}
+
+	var records []doctype.Record
+	switch {
+	case *fromStore:
+		records, err = readStore(cfg, store.Query{Symbols: fs.Args(), From: from, To: to})
+	case *db != "":
+		records, err = readPriceDB(*db, loc, fs.Args(), from, to)
+	case cfg.Ledger.PriceDB == "":
+		err = errors.New("no price DB configured, use -db")
+	default:
+		records, err = readPriceDB(cfg.Ledger.PriceDB, loc, fs.Args(), from, to)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+
+	var b bytes.Buffer
+	w := newWriter(&b, tabular.WithTimes(loc, policy))
+	for _, r := range records {
+		if err := w.Append(r); err != nil {
+			fmt.Fprintf(os.Stderr, "calais: %s: %v\n", r.Symbol, err)
+			return exitError
+		}
+	}
+	return writeOutput(*outPath, b.Bytes(), len(records))
+}
+
+// readPriceDB returns the prices in the price DB at path, read in loc, of
+// the given symbols, or of all symbols, from the day from to the day to.
+func readPriceDB(path string, loc *time.Location, symbols []string, from, to time.Time) ([]doctype.Record, error) {
+	entries, _, err := ledger.ReadFileIn(path, loc)
+	if err != nil {
+		return nil, err
+	}
+	selected := map[string]bool{}
+	for _, s := range symbols {
+		selected[s] = true
+	}
+	var records []doctype.Record
+	for _, e := range entries {
+		switch {
+		case len(selected) > 0 && !selected[e.Symbol]:
+		case !from.IsZero() && e.Day() < from.Format("2006-01-02"):
+		case !to.IsZero() && e.Day() > to.Format("2006-01-02"):
+		default:
+			records = append(records, entryRecord(e))
+		}
+	}
+	return records, nil
+}
+
+// entryRecord returns the record of a price DB entry. Its provider is not
+// known.
+func entryRecord(e ledger.Entry) doctype.Record {
+	kind := "commodity"
+	if config.IsCurrency(e.Symbol) {
+		kind = "currency"
+	}
+	currency := e.Currency()
+	if currency == "" {
+		currency = e.Quote
+	}
+	return doctype.Record{Time: e.Time, Symbol: e.Symbol, Price: e.Price, Kind: kind, Currency: currency}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/tabular"
+)
+
+// inLocal runs the rest of the test with loc as the local time zone.
+func inLocal(t *testing.T, loc *time.Location) {
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestReadPriceDB(t *testing.T) {
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "prices.db")
+	db := "P 2025/09/17 00:00:00 TITC.AT €36.20\n" +
+		"P 2025/09/18 00:00:00 TITC.AT €36.40\n" +
+		"P 2025/09/18 08:29:07 EUR $1.177550\n" +
+		"P 2025/09/19 00:00:00 TITC.AT €36.60\n"
+	if err := os.WriteFile(path, []byte(db), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The times of the price DB are in ledger.timezone, whatever the local
+	// time zone.
+	for _, local := range []*time.Location{time.UTC, newYork} {
+		inLocal(t, local)
+		day := time.Date(2025, 9, 18, 0, 0, 0, 0, local)
+		records, err := readPriceDB(path, athens, nil, day, day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		w := tabular.NewCSV(&b, tabular.WithTimes(athens, doctype.DatesAuto))
+		for _, r := range records {
+			if err := w.Append(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := "symbol,date,price,currency,provider,kind\n" +
+			"TITC.AT,2025-09-18,36.4,EUR,,commodity\n" +
+			"EUR,2025-09-18T08:29:07+03:00,1.17755,USD,,currency\n"
+		if got := b.String(); got != want {
+			t.Errorf("exported in %s:\n%s\nwant:\n%s", local, got, want)
+		}
+	}
+}
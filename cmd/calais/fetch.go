@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+
+	"git.sr.ht/~atmosx/calais/internal/runner"
+)
+
+func runFetch(args []string) int {
+	fs := newFlagSet("fetch", "[fetch] [flags]",
+		"Fetch the latest price of every configured stock and currency pair, evaluate\n"+
+			"derived prices and append them to the price DB. The -symbol, -pair, -provider\n"+
+			"and -tag flags restrict the run to some of the configured entries.\n\n"+
+			"Exits with status 0 when every price was written, 3 when some failed and 4\n"+
+			"when all of them failed.")
+	g := addGlobals(fs)
+	showVersion := fs.Bool("version", false, "show version information")
+	filter := addFilter(fs)
+	dryRun := fs.Bool("dry-run", false, "fetch everything but write nothing; print the changes as a diff (to stderr with -report -)")
+	reportPath := addReport(fs)
+	noCache := addCache(fs)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if *showVersion {
+		return runVersion(nil)
+	}
+
+	logger := g.logger(*dryRun || *reportPath == "-")
+	cfg, ok := g.load(logger)
+	if !ok {
+		return exitError
+	}
+
+	sources, errs := runner.Build(cfg, httpClient(cfg, *noCache), logger)
+	for _, err := range errs {
+		logger.Error("failed to create provider", "error", err)
+	}
+
+	sources, derived := filter.Sources(sources), filter.Derived(cfg.Derived)
+	if !filter.IsZero() && len(sources) == 0 && len(derived) == 0 {
+		logger.Error("nothing matches the given filters")
+		return exitUsage
+	}
+
+	if !*dryRun {
+		if err := cfg.Ledger.CheckWritable(); err != nil {
+			logger.Error("cannot write the price DB", "error", err)
+			return exitError
+		}
+	}
+	out, err := newOutput(cfg, *dryRun)
+	if err != nil {
+		logger.Error("failed to open the price store", "error", err)
+		return exitError
+	}
+	if *reportPath == "-" {
+		// The report alone goes to stdout.
+		out.diff = os.Stderr
+	}
+	opts, err := out.runOptions(cfg)
+	if err != nil {
+		logger.Error("failed to read the price DB", "error", err)
+		out.finish()
+		return exitError
+	}
+	report := runner.New(sources, derived, out, logger, opts...).Run()
+	report.AddBuildErrors(errs)
+	if err := out.finish(); err != nil {
+		logger.Error("failed to finish writing", "error", err)
+		return exitError
+	}
+	return finishReport(report, *reportPath, logger)
+}
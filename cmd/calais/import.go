@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/ledger"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/store"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/tabular"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+func runImport(args []string) int {
+	fs := newFlagSet("import", "import [flags] FILE...",
+		"Merge prices from CSV files, e.g. broker statements, pricehist output or\n"+
+			"calais export, or from ledger price files into the price DB. A file named -\n"+
+			"is read from stdin. Every price is checked first and nothing is imported\n"+
+			"when one is invalid, unless -skip-invalid is given. An imported price\n"+
+			"replaces an existing price of the same symbol on the same day. Prices\n"+
+			"quoted in a minor unit such as GBX are converted to the major unit.\n\n"+
+			"CSV files have a header line naming the columns symbol, date, price and\n"+
+			"optionally currency, provider and kind. -map assigns fields to other\n"+
+			"columns by name or number, e.g. -map symbol=Ticker,date=1,price=Close.")
+	g := addGlobals(fs)
+	format := fs.String("format", "csv", "input format, csv or ledger")
+	var mapping, dateFormats listFlag
+	fs.Var(&mapping, "map", "read a field from a column, FIELD=NAME or FIELD=NUMBER (repeatable, comma separated)")
+	noHeader := fs.Bool("no-header", false, "the first line of a CSV file is a price; map columns by number")
+	delimiter := fs.String("delimiter", ",", "CSV field delimiter, a single character or tab")
+	decimal := fs.String("decimal", ".", "decimal separator of prices, . or , (the other one groups thousands)")
+	fs.Var(&dateFormats, "date-format", "Go layout of dates, e.g. 02.01.2006 (repeatable; default ISO dates and timestamps)")
+	timezone := fs.String("timezone", "", "time zone of dates without one (default ledger.timezone)")
+	symbol := fs.String("symbol", "", "symbol of rows without one")
+	currency := fs.String("currency", "", "currency of rows without one")
+	skipInvalid := fs.Bool("skip-invalid", false, "import the valid prices when some are invalid")
+	dryRun := fs.Bool("dry-run", false, "write nothing; print the changes to the price DB as a diff")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg, err := g.loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "calais: failed to load config: %v\n", err)
+		return exitError
+	}
+	// Prices are written in ledger.timezone; -timezone only says how the
+	// files are to be read.
+	ledgerLoc, _ := cfg.Ledger.Location()
+	loc := ledgerLoc
+	if *timezone != "" {
+		if loc, err = time.LoadLocation(*timezone); err != nil {
+			fmt.Fprintf(os.Stderr, "calais: invalid -timezone: %v\n", err)
+			return exitUsage
+		}
+	}
+	m := tabular.Mapping{
+		Columns:     map[string]string{},
+		NoHeader:    *noHeader,
+		Symbol:      *symbol,
+		Currency:    *currency,
+		DateFormats: dateFormats,
+		Location:    loc,
+	}
+	for _, kv := range mapping {
+		field, column, ok := strings.Cut(kv, "=")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "calais: invalid -map %q, expected FIELD=COLUMN\n", kv)
+			return exitUsage
+		}
+		m.Columns[strings.TrimSpace(field)] = strings.TrimSpace(column)
+	}
+	switch *delimiter {
+	case "tab", `\t`:
+		m.Comma = '\t'
+	default:
+		if utf8.RuneCountInString(*delimiter) != 1 {
+			fmt.Fprintf(os.Stderr, "calais: invalid -delimiter %q, expected a single character\n", *delimiter)
+			return exitUsage
+		}
+		m.Comma, _ = utf8.DecodeRuneInString(*delimiter)
+	}
+	switch *decimal {
+	case ".", ",":
+		m.Decimal = rune((*decimal)[0])
+	default:
+		fmt.Fprintf(os.Stderr, "calais: invalid -decimal %q, expected . or ,\n", *decimal)
+		return exitUsage
+	}
+	if *format != "csv" && *format != "ledger" {
+		fmt.Fprintf(os.Stderr, "calais: invalid -format %q, expected csv or ledger\n", *format)
+		return exitUsage
+	}
+
+	var (
+		records []doctype.Record
+		invalid int
+	)
+	for _, path := range fs.Args() {
+		recs, errs, err := readImport(path, *format, m)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "calais: %s: %v\n", path, err)
+			return exitError
+		}
+		for _, r := range recs {
+			if err := checkImport(&r); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			records = append(records, r)
+		}
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "calais: %s: %v\n", path, err)
+		}
+		invalid += len(errs)
+	}
+	if invalid > 0 && !*skipInvalid {
+		fmt.Fprintf(os.Stderr, "calais: %d invalid prices, nothing imported; use -skip-invalid to import the others\n", invalid)
+		return exitError
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+
+	w := ledger.NewWriter(cfg.Ledger.PriceDB, ledger.WithTimes(ledgerLoc, cfg.Ledger.Dates), ledger.WithDedup())
+	if *dryRun {
+		p := ledger.NewPreview(w)
+		for _, r := range records {
+			if err := p.Append(r); err != nil {
+				fmt.Fprintf(os.Stderr, "calais: %s: %v\n", r.Symbol, err)
+				return exitError
+			}
+		}
+		if err := p.Diff(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "calais:", err)
+			return exitError
+		}
+		return exitOK
+	}
+	if err := cfg.Ledger.CheckWritable(); err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	if err := w.AppendAll(records); err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	if err := storeRecords(cfg, records); err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	fmt.Printf("imported %d prices into %s\n", len(records), cfg.Ledger.PriceDB)
+	return exitOK
+}
+
+// readImport reads the prices of a file in format. Times without a zone,
+// such as all those of ledger files, are in m.Location.
+func readImport(path, format string, m tabular.Mapping) ([]doctype.Record, []error, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	if format == "csv" {
+		return tabular.Read(r, m)
+	}
+	entries, errs, err := ledger.ParseIn(r, m.Location)
+	records := make([]doctype.Record, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, entryRecord(e))
+	}
+	return records, errs, err
+}
+
+// checkImport checks the currency and kind of an imported price, converting
+// minor units, and sets the kind when the file has none.
+func checkImport(r *doctype.Record) error {
+	r.Price, r.Currency = providers.MajorUnit(r.Price, r.Currency)
+	day := r.Time.Format("2006-01-02")
+	if r.Currency != "" && !config.IsCurrency(r.Currency) {
+		return fmt.Errorf("%s on %s: %q is not an ISO 4217 currency code", r.Symbol, day, r.Currency)
+	}
+	switch r.Kind {
+	case "":
+		r.Kind = "commodity"
+		if config.IsCurrency(r.Symbol) {
+			r.Kind = "currency"
+		}
+	case "commodity", "currency":
+	default:
+		return fmt.Errorf("%s on %s: unknown kind %q, expected commodity or currency", r.Symbol, day, r.Kind)
+	}
+	// The store gets the currency the price DB is written in.
+	if r.Currency == "" {
+		r.Currency = ledger.DefaultCurrency(r.Kind)
+	}
+	return nil
+}
+
+// storeRecords adds prices already written to the price DB to the price
+// history store, if one is configured.
+func storeRecords(cfg *config.Config, records []doctype.Record) error {
+	if cfg.Store.Path == "" {
+		return nil
+	}
+	s, err := store.Open(cfg.Store.Path)
+	if err == nil {
+		err = s.AppendAll(records)
+		if cerr := s.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("written to the price DB but not to the store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/tabular"
+)
+
+func TestReadImport_Ledger(t *testing.T) {
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inLocal(t, time.UTC)
+	path := filepath.Join(t.TempDir(), "prices.db")
+	if err := os.WriteFile(path, []byte("P 2025/09/18 08:29:07 EUR $1.177550\nP 2025/09/18 X\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ledger files are read in ledger.timezone or the -timezone given.
+	for _, loc := range []*time.Location{athens, newYork} {
+		records, errs, err := readImport(path, "ledger", tabular.Mapping{Location: loc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "line 2:") {
+			t.Errorf("expected an error for line 2, got %v", errs)
+		}
+		want := time.Date(2025, 9, 18, 8, 29, 7, 0, loc)
+		if len(records) != 1 || !records[0].Time.Equal(want) {
+			t.Fatalf("read in %s: %+v, want a price at %v", loc, records, want)
+		}
+		if r := records[0]; r.Symbol != "EUR" || r.Price != 1.17755 || r.Currency != "USD" || r.Kind != "currency" {
+			t.Errorf("unexpected record %+v", r)
+		}
+	}
+}
+
+func TestCheckImport(t *testing.T) {
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		r    doctype.Record
+		want doctype.Record
+		err  string
+	}{
+		{
+			name: "minor unit",
+			r:    doctype.Record{Symbol: "VOD.L", Price: 7215, Currency: "GBX"},
+			want: doctype.Record{Symbol: "VOD.L", Price: 72.15, Currency: "GBP", Kind: "commodity"},
+		},
+		{
+			name: "currency kind",
+			r:    doctype.Record{Symbol: "EUR", Price: 1.1812, Currency: "USD"},
+			want: doctype.Record{Symbol: "EUR", Price: 1.1812, Currency: "USD", Kind: "currency"},
+		},
+		{
+			name: "default currency",
+			r:    doctype.Record{Symbol: "AAPL", Price: 237.88},
+			want: doctype.Record{Symbol: "AAPL", Price: 237.88, Currency: "EUR", Kind: "commodity"},
+		},
+		{
+			name: "default currency of a currency",
+			r:    doctype.Record{Symbol: "GBP", Price: 1.35, Kind: "currency"},
+			want: doctype.Record{Symbol: "GBP", Price: 1.35, Currency: "USD", Kind: "currency"},
+		},
+		{
+			name: "invalid currency",
+			r:    doctype.Record{Symbol: "AAPL", Price: 237.88, Currency: "Dollar"},
+			err:  `AAPL on 2025-09-18: "Dollar" is not an ISO 4217 currency code`,
+		},
+		{
+			name: "unknown kind",
+			r:    doctype.Record{Symbol: "AAPL", Price: 237.88, Currency: "USD", Kind: "stock"},
+			err:  `AAPL on 2025-09-18: unknown kind "stock", expected commodity or currency`,
+		},
+	}
+	for _, tt := range tests {
+		r := tt.r
+		r.Time = day
+		err := checkImport(&r)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		tt.want.Time = day
+		if err != nil || r != tt.want {
+			t.Errorf("%s: got %+v, %v; want %+v", tt.name, r, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/internal/runner"
+	"git.sr.ht/~atmosx/calais/pkg/log"
+)
+
+func runList(args []string) int {
+	fs := newFlagSet("list", "list [flags]",
+		"List the configured provider instances with the stocks, currency pairs and\n"+
+			"derived prices they produce.")
+	g := addGlobals(fs)
+	filter := addFilter(fs)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	logger := log.New(os.Stderr, g.logLevel)
+	cfg, ok := g.load(logger)
+	if !ok {
+		return exitError
+	}
+	sources, errs := runner.Build(cfg, http.DefaultClient, logger)
+	for _, err := range errs {
+		logger.Error("failed to create provider", "error", err)
+	}
+
+	printList(os.Stdout, filter.Sources(sources), filter.Derived(cfg.Derived))
+	return exitOK
+}
+
+func printList(out io.Writer, sources []runner.Source, derived []config.DerivedConfig) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tTYPE\tKIND\tSYMBOL\tEXCHANGE\tTAGS")
+	for _, s := range sources {
+		for _, st := range s.Stocks() {
+			exchange := "-"
+			if e, ok := st.Calendar(); ok {
+				exchange = e.Code
+			}
+			fmt.Fprintf(w, "%s\t%s\tstock\t%s\t%s\t%s\n", s.Config.Name, s.Config.ProviderType(), st.Symbol, exchange, strings.Join(st.Tags, ","))
+		}
+		for _, p := range s.Pairs() {
+			fmt.Fprintf(w, "%s\t%s\tpair\t%s\t-\t%s\n", s.Config.Name, s.Config.ProviderType(), p, strings.Join(p.Tags, ","))
+		}
+	}
+	for _, d := range derived {
+		fmt.Fprintf(w, "%s\tderived\tderived\t%s = %s\t-\t%s\n", runner.DerivedProvider, d.Symbol, d.Expr, strings.Join(d.Tags, ","))
+	}
+	w.Flush()
+}
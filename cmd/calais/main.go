@@ -1,18 +1,24 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/internal/runner"
 	"git.sr.ht/~atmosx/calais/pkg/doctype"
 	"git.sr.ht/~atmosx/calais/pkg/doctype/ledger"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/store"
+	"git.sr.ht/~atmosx/calais/pkg/httpcache"
 	"git.sr.ht/~atmosx/calais/pkg/log"
 	"git.sr.ht/~atmosx/calais/pkg/providers"
-	"git.sr.ht/~atmosx/calais/pkg/providers/fixer"
-	"git.sr.ht/~atmosx/calais/pkg/providers/marketstack"
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/all"
 )
 
 var (
@@ -21,69 +27,384 @@ var (
 	date    = "someDay"
 )
 
+// Exit codes shared by all commands. Commands that fetch prices exit with
+// exitPartial when some prices failed and exitFailed when all of them did.
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitUsage   = 2
+	exitPartial = 3
+	exitFailed  = 4
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(args []string) int
+}
+
+// commands is populated in init to allow the help command to refer to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"fetch", "fetch the latest prices and write them to the price DB (default)", runFetch},
+		{"backfill", "fetch and write historical prices for a date range", runBackfill},
+		{"serve", "run in the foreground and fetch prices on the configured schedules", runServe},
+		{"list", "list the configured providers, stocks and currency pairs", runList},
+		{"query", "print prices from the price DB", runQuery},
+		{"verify", "check the price DB for malformed and duplicate entries", runVerify},
+		{"prune", "remove duplicate or old entries from the price DB", runPrune},
+		{"approve", "move quarantined outliers into the price DB", runApprove},
+		{"regenerate", "write a price DB from the price history store", runRegenerate},
+		{"export", "convert the price DB or store to CSV or JSON Lines", runExport},
+		{"import", "merge prices from CSV or ledger files into the price DB", runImport},
+		{"config", "work with the configuration file (config validate)", runConfig},
+		{"providers", "list the available provider types", runProviders},
+		{"version", "show version information", runVersion},
+		{"help", "show help for a command", runHelp},
+	}
+}
+
 func main() {
-	configPath := flag.String("c", "/etc/calais/config.yaml", "path to configuration file")
-	logLevel := flag.String("l", "Info", "log level (Info, debug)")
-	showVersion := flag.Bool("version", false, "show version information")
-	flag.Parse()
+	os.Exit(run(os.Args[1:]))
+}
 
-	if *showVersion {
-		fmt.Printf("calais version %s, commit %s, built at %s\n", version, commit, date)
-		os.Exit(0)
+// run dispatches to a command. A bare invocation, or one starting with a
+// flag, is a fetch so that existing cron entries keep working.
+func run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+			usage(os.Stdout)
+			return exitOK
+		}
+		return runFetch(args)
 	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "calais: unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
 
-	logger := log.New(os.Stdout, *logLevel)
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: calais [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(w, "\nRun 'calais help <command>' for the flags of a command.\n")
+}
+
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stdout)
+		return exitOK
+	}
+	return run(append(args, "-h"))
+}
 
-	cfg, err := config.LoadConfig(*configPath)
+func runVersion(args []string) int {
+	fmt.Printf("calais version %s, commit %s, built at %s\n", version, commit, date)
+	return exitOK
+}
+
+// newFlagSet returns a flag set for a command whose help shows the usage line
+// and description.
+func newFlagSet(name, usageLine, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: calais %s\n\n%s\n", usageLine, description)
+		var hasFlags bool
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(w, "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parse parses args and maps the outcome to an exit code. ok is false when
+// the command should return the code right away.
+func parse(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// globals are the flags every command that reads the configuration accepts.
+type globals struct {
+	configPaths listFlag
+	logLevel    string
+}
+
+func addGlobals(fs *flag.FlagSet) *globals {
+	g := &globals{}
+	fs.Var(&g.configPaths, "c", "path to configuration file; repeat to layer files, later ones override earlier ones\n"+
+		"(default: $CALAIS_CONFIG, or the first of "+strings.Join(config.SearchPath(), ", ")+")")
+	fs.StringVar(&g.logLevel, "l", "Info", "log level (Info, debug)")
+	return g
+}
+
+// paths returns the configuration files given with -c, or else the ones
+// found by config.Find.
+func (g *globals) paths() ([]string, error) {
+	if len(g.configPaths) > 0 {
+		return g.configPaths, nil
+	}
+	return config.Find()
+}
+
+// loadConfig loads the configuration files given with -c or found by
+// config.Find.
+func (g *globals) loadConfig() (*config.Config, error) {
+	paths, err := g.paths()
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadConfig(paths...)
+}
+
+// logger logs to stdout, or to stderr when stdout carries the command's
+// output, e.g. a dry-run diff.
+func (g *globals) logger(quiet bool) *log.Logger {
+	if quiet {
+		return log.New(os.Stderr, g.logLevel)
+	}
+	return log.New(os.Stdout, g.logLevel)
+}
+
+func (g *globals) load(logger *log.Logger) (*config.Config, bool) {
+	cfg, err := g.loadConfig()
 	if err != nil {
 		logger.Error("failed to load config", "error", err)
-		os.Exit(1)
+		return nil, false
 	}
+	return cfg, true
+}
+
+// listFlag is a flag that may be repeated or given a comma separated list.
+type listFlag []string
 
-	stockProvider := marketstack.New(cfg.Marketstack.Key, http.DefaultClient, logger)
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
 
-	var currencyProvider providers.CurrencyProvider
-	if cfg.Fixer.Key != "" {
-		currencyProvider = fixer.New(cfg.Fixer.Key, http.DefaultClient, logger)
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
 	}
+	return nil
+}
 
-	writer := ledger.NewWriter(cfg.Ledger.PriceDB)
+// addFilter registers the flags that select what a command works on.
+func addFilter(fs *flag.FlagSet) *runner.Filter {
+	f := &runner.Filter{}
+	fs.Var((*listFlag)(&f.Symbols), "symbol", "only process these stock or derived symbols (repeatable, comma separated)")
+	fs.Var((*listFlag)(&f.Pairs), "pair", "only process these currency pairs, e.g. EUR/USD (repeatable, comma separated)")
+	fs.Var((*listFlag)(&f.Providers), "provider", "only process these provider instances, \"derived\" for derived prices (repeatable, comma separated)")
+	fs.Var((*listFlag)(&f.Tags), "tag", "only process entries with one of these tags (repeatable, comma separated)")
+	return f
+}
 
-	for _, symbol := range cfg.Marketstack.Stocks {
-		sd, err := stockProvider.FetchStock(symbol)
-		if err != nil {
-			logger.Error("failed to fetch stock", "symbol", symbol, "error", err)
-			continue
+// output is where the records of a run go. In dry-run mode nothing is written
+// and finish prints the diff the run would have caused to diff, stdout by
+// default.
+type output struct {
+	doctype.PriceWriter
+	quarantine doctype.PriceWriter
+	previews   []*ledger.Preview
+	diff       io.Writer
+	store      *store.Store
+}
+
+func newOutput(cfg *config.Config, dryRun bool) (*output, error) {
+	// The time zone was checked when the configuration was loaded.
+	loc, _ := cfg.Ledger.Location()
+	times := ledger.WithTimes(loc, cfg.Ledger.Dates)
+	opts := []ledger.Option{times}
+	if cfg.Ledger.Dedup {
+		opts = append(opts, ledger.WithDedup())
+	}
+	w := ledger.NewWriter(cfg.Ledger.PriceDB, opts...)
+	// An outlier fetched again replaces the one awaiting approval.
+	q := ledger.NewWriter(cfg.Ledger.Quarantine, times, ledger.WithDedup())
+	if dryRun {
+		p, qp := ledger.NewPreview(w), ledger.NewPreview(q)
+		return &output{PriceWriter: p, quarantine: qp, previews: []*ledger.Preview{p, qp}, diff: os.Stdout}, nil
+	}
+	if cfg.Store.Path == "" {
+		return &output{PriceWriter: w, quarantine: q}, nil
+	}
+	// Quarantined prices reach the store when they are approved.
+	s, err := store.Open(cfg.Store.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &output{PriceWriter: doctype.Tee(w, storeMirror{s}), quarantine: q, store: s}, nil
+}
+
+// storeMirror writes to the store the prices written to the price DB before
+// it, and says so when it fails, so that the price is not taken for missing.
+type storeMirror struct {
+	s *store.Store
+}
+
+func (m storeMirror) Append(r doctype.Record) error {
+	if err := m.s.Append(r); err != nil {
+		return fmt.Errorf("written to the price DB but not to the store: %w", err)
+	}
+	return nil
+}
+
+// runOptions returns the options for the checks and conversions cfg
+// configures. Outliers are judged, and prices converted in the absence of
+// fresh rates, against the price DB as it is now.
+func (o *output) runOptions(cfg *config.Config) ([]runner.Option, error) {
+	opts := []runner.Option{runner.WithStaleness(cfg.Staleness)}
+	if cfg.Outliers.MaxChange == 0 && cfg.Ledger.Currency == "" {
+		return opts, nil
+	}
+	entries, _, err := ledger.ReadFile(cfg.Ledger.PriceDB)
+	if err != nil {
+		return nil, err
+	}
+	latest := ledger.Latest(entries)
+
+	if cfg.Outliers.MaxChange > 0 {
+		last := map[string]float64{}
+		for _, e := range latest {
+			last[runner.QuoteKey(e.Symbol, e.Currency())] = e.Price
 		}
-		if err := writer.Append(doctype.Record{
-			Time:   sd.Date,
-			Symbol: sd.Symbol,
-			Price:  sd.Close,
-			Kind:   "commodity",
-		}); err != nil {
-			logger.Error("failed to write stock price", "symbol", symbol, "error", err)
-			continue
+		quarantine := o.quarantine
+		if cfg.Outliers.Action == config.OutlierReject {
+			quarantine = nil
 		}
-		logger.Info("wrote stock price", "symbol", sd.Symbol, "price", sd.Close, "date", sd.Date)
+		opts = append(opts, runner.WithOutliers(cfg.Outliers.MaxChange, last, quarantine))
 	}
-
-	if currencyProvider != nil {
-		for _, p := range cfg.Fixer.Pairs {
-			cd, err := currencyProvider.FetchCurrency(p.From, p.To)
-			if err != nil {
-				logger.Error("failed to fetch currency", "pair", p.From+"/"+p.To, "error", err)
-				continue
+	if cfg.Ledger.Currency != "" {
+		var known []runner.Rate
+		for _, e := range latest {
+			if to := e.Currency(); to != "" && config.IsCurrency(e.Symbol) {
+				known = append(known, runner.Rate{From: e.Symbol, To: to, Rate: e.Price})
 			}
-			if err := writer.Append(doctype.Record{
-				Time:   cd.Date,
-				Symbol: cd.From,
-				Price:  cd.Rate,
-				Kind:   "currency",
-			}); err != nil {
-				logger.Error("failed to write currency price", "pair", p.From+"/"+p.To, "error", err)
-				continue
-			}
-			logger.Info("wrote currency price", "pair", p.From+"/"+p.To, "rate", cd.Rate, "date", cd.Date)
 		}
+		opts = append(opts, runner.WithCurrency(cfg.Ledger.Currency, known))
+	}
+	return opts, nil
+}
+
+// finish prints the diffs of a dry run and closes the store.
+func (o *output) finish() error {
+	for _, p := range o.previews {
+		if err := p.Diff(o.diff); err != nil {
+			return err
+		}
+	}
+	if o.store != nil {
+		return o.store.Close()
+	}
+	return nil
+}
+
+// addCache registers the flag that bypasses the HTTP cache.
+func addCache(fs *flag.FlagSet) *bool {
+	return fs.Bool("no-cache", false, "send every request to the providers instead of using cached responses")
+}
+
+// httpClient returns the client providers send their requests with, caching
+// responses unless noCache is set or the configuration disables it.
+func httpClient(cfg *config.Config, noCache bool) providers.HTTPDoer {
+	if noCache || cfg.Cache.Disabled {
+		return http.DefaultClient
+	}
+	dir, ttl := cfg.Cache.Dir, cfg.Cache.TTL
+	if dir == "" {
+		dir = httpcache.DefaultDir()
+	}
+	if ttl == 0 {
+		ttl = httpcache.DefaultTTL
+	}
+	return httpcache.New(http.DefaultClient, dir, ttl)
+}
+
+// addReport registers the flag for the machine-readable run report.
+func addReport(fs *flag.FlagSet) *string {
+	return fs.String("report", "", "write a JSON run report to this file (- for stdout)")
+}
+
+// finishReport writes the report if requested and maps its outcome to an exit
+// code.
+func finishReport(report *runner.Report, path string, logger *log.Logger) int {
+	if path != "" {
+		var err error
+		if path == "-" {
+			err = report.WriteJSON(os.Stdout)
+		} else {
+			err = writeReport(report, path)
+		}
+		if err != nil {
+			logger.Error("failed to write report", "error", err)
+			return exitError
+		}
+	}
+
+	failed := report.Failed()
+	switch {
+	case failed == 0:
+		return exitOK
+	case failed == len(report.Results):
+		return exitFailed
+	}
+	return exitPartial
+}
+
+func writeReport(report *runner.Report, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// priceDB returns the price DB path, preferring an explicit -db flag over the
+// configuration.
+func (g *globals) priceDB(override string) (string, bool) {
+	if override != "" {
+		return override, true
+	}
+	cfg, err := g.loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "calais: failed to load config: %v\n", err)
+		return "", false
+	}
+	if cfg.Ledger.PriceDB == "" {
+		fmt.Fprintf(os.Stderr, "calais: no price DB configured, use -db\n")
+		return "", false
+	}
+	return cfg.Ledger.PriceDB, true
+}
+
+// parseDate reads a YYYY-MM-DD flag value. An empty value yields def.
+func parseDate(name, value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s %q, expected YYYY-MM-DD", name, value)
 	}
+	return t, nil
 }
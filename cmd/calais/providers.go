@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+func runProviders(args []string) int {
+	fs := newFlagSet("providers", "providers",
+		"List the provider types that can be used in the providers section of the\n"+
+			"configuration.")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	for _, typ := range providers.Types() {
+		fmt.Println(typ)
+	}
+	return exitOK
+}
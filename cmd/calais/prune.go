@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype/ledger"
+)
+
+func runPrune(args []string) int {
+	fs := newFlagSet("prune", "prune [flags]",
+		"Remove entries from the price DB: prices superseded by a later price of the\n"+
+			"same symbol in the same quote on the same day and, with -before, all prices\n"+
+			"older than a date. Comments and other lines are kept.")
+	g := addGlobals(fs)
+	db := fs.String("db", "", "price DB to prune (default ledger.price_db from the config)")
+	dedup := fs.Bool("dedup", true, "remove duplicate prices of a symbol on the same day")
+	beforeFlag := fs.String("before", "", "remove prices dated before this day (YYYY-MM-DD)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	before, err := parseDate("before", *beforeFlag, time.Time{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+	path, ok := g.priceDB(*db)
+	if !ok {
+		return exitError
+	}
+
+	entries, _, err := ledger.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	drop := map[int]bool{}
+	if *dedup {
+		for _, e := range ledger.Duplicates(entries) {
+			drop[e.Line] = true
+		}
+	}
+
+	n, err := ledger.Rewrite(path, func(e ledger.Entry) bool {
+		return drop[e.Line] || (!before.IsZero() && e.Time.Before(before))
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	fmt.Printf("removed %d entries from %s\n", n, path)
+	return exitOK
+}
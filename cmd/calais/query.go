@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype/ledger"
+)
+
+func runQuery(args []string) int {
+	fs := newFlagSet("query", "query [flags] [SYMBOL...]",
+		"Print the price DB entries of the given symbols, or of all symbols, within\n"+
+			"an optional date range.")
+	g := addGlobals(fs)
+	db := fs.String("db", "", "price DB to read (default ledger.price_db from the config)")
+	fromFlag := fs.String("from", "", "first day to include (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "last day to include (YYYY-MM-DD)")
+	latest := fs.Bool("latest", false, "only print the latest entry of each symbol in each quote")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	from, err := parseDate("from", *fromFlag, time.Time{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+	to, err := parseDate("to", *toFlag, time.Time{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+	path, ok := g.priceDB(*db)
+	if !ok {
+		return exitError
+	}
+
+	entries, _, err := ledger.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+
+	symbols := map[string]bool{}
+	for _, s := range fs.Args() {
+		symbols[s] = true
+	}
+	var matched []ledger.Entry
+	for _, e := range entries {
+		switch {
+		case len(symbols) > 0 && !symbols[e.Symbol]:
+		case !from.IsZero() && e.Time.Before(from):
+		case !to.IsZero() && !e.Time.Before(to.AddDate(0, 0, 1)):
+		default:
+			matched = append(matched, e)
+		}
+	}
+	if *latest {
+		matched = ledger.Latest(matched)
+	}
+	for _, e := range matched {
+		fmt.Println(e.Text)
+	}
+	return exitOK
+}
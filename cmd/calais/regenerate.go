@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/beancount"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/ledger"
+	"git.sr.ht/~atmosx/calais/pkg/doctype/store"
+)
+
+func runRegenerate(args []string) int {
+	fs := newFlagSet("regenerate", "regenerate [flags] [SYMBOL...]",
+		"Write a price DB from the price history store configured under store:, for\n"+
+			"all symbols or only the given ones, in chronological order. Prices are\n"+
+			"written as fetch writes them, following the ledger: settings; with\n"+
+			"ledger.dedup only the last price of a symbol on a day is kept. With\n"+
+			"-format beancount, prices are written as beancount price directives, one\n"+
+			"per symbol and day. calais export -store writes CSV and JSON Lines.")
+	g := addGlobals(fs)
+	outPath := fs.String("o", "-", "file to write, replacing it (- for stdout)")
+	format := fs.String("format", "ledger", "output format, ledger or beancount")
+	fromFlag := fs.String("from", "", "first day to include (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "last day to include (YYYY-MM-DD)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	from, err := parseDate("from", *fromFlag, time.Time{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+	to, err := parseDate("to", *toFlag, time.Time{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitUsage
+	}
+	switch *format {
+	case "ledger", "beancount":
+	case "csv", "jsonl":
+		fmt.Fprintf(os.Stderr, "calais: invalid -format %q, use calais export -store for CSV and JSON Lines\n", *format)
+		return exitUsage
+	default:
+		fmt.Fprintf(os.Stderr, "calais: invalid -format %q, expected ledger or beancount\n", *format)
+		return exitUsage
+	}
+	cfg, err := g.loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "calais: failed to load config: %v\n", err)
+		return exitError
+	}
+	records, err := readStore(cfg, store.Query{Symbols: fs.Args(), From: from, To: to})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+
+	loc, _ := cfg.Ledger.Location()
+	if *format == "beancount" {
+		// Beancount has one price of a commodity per day.
+		records = lastOfDay(records, loc)
+		var b bytes.Buffer
+		w := beancount.New(&b, loc)
+		for _, r := range records {
+			if err := w.Append(r); err != nil {
+				fmt.Fprintln(os.Stderr, "calais:", err)
+				return exitError
+			}
+		}
+		return writeOutput(*outPath, b.Bytes(), len(records))
+	}
+	if cfg.Ledger.Dedup {
+		records = lastOfDay(records, loc)
+	}
+	// The preview formats the prices without writing them.
+	p := ledger.NewPreview(ledger.NewWriter(*outPath, ledger.WithTimes(loc, cfg.Ledger.Dates)))
+	for _, r := range records {
+		if err := p.Append(r); err != nil {
+			fmt.Fprintf(os.Stderr, "calais: %s: %v\n", r.Symbol, err)
+			return exitError
+		}
+	}
+	data := ""
+	if lines := p.Lines(); len(lines) > 0 {
+		data = strings.Join(lines, "\n") + "\n"
+	}
+	return writeOutput(*outPath, []byte(data), len(records))
+}
+
+// readStore returns the prices q selects from the price history store.
+func readStore(cfg *config.Config, q store.Query) ([]doctype.Record, error) {
+	if cfg.Store.Path == "" {
+		return nil, errors.New("no price store configured, set store.path")
+	}
+	s, err := store.Open(cfg.Store.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	var records []doctype.Record
+	err = s.Range(q, func(r doctype.Record) error {
+		// Prices stored without a currency are in the price DB in the
+		// one it assumes.
+		if r.Currency == "" {
+			r.Currency = ledger.DefaultCurrency(r.Kind)
+		}
+		records = append(records, r)
+		return nil
+	})
+	return records, err
+}
+
+// writeOutput writes the n prices in data to stdout when path is -, or else
+// replaces the file at path.
+func writeOutput(path string, data []byte, n int) int {
+	if path == "-" {
+		os.Stdout.Write(data)
+		return exitOK
+	}
+	if err := ledger.WriteFileAtomic(path, data); err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d prices to %s\n", n, path)
+	return exitOK
+}
+
+// lastOfDay keeps the last of the records of a symbol in the same quote on
+// the same day in loc, the price ledger.WithDedup would leave behind.
+func lastOfDay(records []doctype.Record, loc *time.Location) []doctype.Record {
+	key := func(r doctype.Record) string {
+		return r.Symbol + "\x00" + r.Currency + "\x00" + doctype.In(r.Time, loc).Format("2006-01-02")
+	}
+	last := map[string]int{}
+	for i, r := range records {
+		last[key(r)] = i
+	}
+	var kept []doctype.Record
+	for i, r := range records {
+		if last[key(r)] == i {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/internal/runner"
+	"git.sr.ht/~atmosx/calais/internal/scheduler"
+	"git.sr.ht/~atmosx/calais/pkg/calendar"
+)
+
+func runServe(args []string) int {
+	fs := newFlagSet("serve", "serve [flags]",
+		"Run in the foreground and fetch prices on the cron schedules listed under\n"+
+			"schedules: in the configuration. Each schedule runs the same pipeline as\n"+
+			"fetch for the entries it selects. A run missed while calais was not running\n"+
+			"is made up on startup. On SIGINT or SIGTERM a run in progress is finished\n"+
+			"before exiting.")
+	g := addGlobals(fs)
+	statePath := fs.String("state", scheduler.DefaultStatePath(), "file recording the last run of each schedule")
+	noCache := addCache(fs)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	logger := g.logger(false)
+	cfg, ok := g.load(logger)
+	if !ok {
+		return exitError
+	}
+	if len(cfg.Schedules) == 0 {
+		logger.Error("no schedules configured")
+		return exitError
+	}
+	if err := cfg.Ledger.CheckWritable(); err != nil {
+		logger.Error("cannot write the price DB", "error", err)
+		return exitError
+	}
+
+	sources, errs := runner.Build(cfg, httpClient(cfg, *noCache), logger)
+	for _, err := range errs {
+		logger.Error("failed to create provider", "error", err)
+	}
+
+	var jobs []scheduler.Job
+	for _, sc := range cfg.Schedules {
+		schedule, err := sc.Schedule()
+		if err != nil {
+			logger.Error("invalid schedule", "schedule", sc.Name, "error", err)
+			return exitError
+		}
+		filter := runner.Filter{Symbols: sc.Symbols, Pairs: sc.Pairs, Providers: sc.Providers, Tags: sc.Tags}
+		selected, derived := filter.Sources(sources), filter.Derived(cfg.Derived)
+		if !filter.IsZero() && len(selected) == 0 && len(derived) == 0 {
+			logger.Error("schedule selects nothing", "schedule", sc.Name)
+			return exitError
+		}
+
+		var exchange *calendar.Exchange
+		if sc.Exchange != "" {
+			exchange, _ = calendar.Lookup(sc.Exchange)
+		}
+
+		name := sc.Name
+		jobs = append(jobs, scheduler.Job{
+			Name:     name,
+			Schedule: schedule,
+			Jitter:   sc.Jitter,
+			Run: func() {
+				if exchange != nil && !exchange.IsTradingDay(time.Now()) {
+					logger.Info("skipping scheduled run, exchange closed", "schedule", name, "exchange", exchange.Code)
+					return
+				}
+				out, err := newOutput(cfg, false)
+				if err != nil {
+					logger.Error("failed to open the price store", "schedule", name, "error", err)
+					return
+				}
+				opts, err := out.runOptions(cfg)
+				if err != nil {
+					logger.Error("failed to read the price DB", "schedule", name, "error", err)
+					out.finish()
+					return
+				}
+				report := runner.New(selected, derived, out, logger, opts...).Run()
+				if err := out.finish(); err != nil {
+					logger.Error("failed to close the price store", "schedule", name, "error", err)
+				}
+				logger.Info("finished scheduled run", "schedule", name, "prices", len(report.Results), "failed", report.Failed(),
+					"stale", len(report.Stale()), "quarantined", len(report.Quarantined()), "unconverted", len(report.Unconverted()))
+			},
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.Info("serving", "schedules", len(jobs), "state", *statePath)
+	if err := scheduler.New(jobs, *statePath, logger).Run(ctx); err != nil {
+		logger.Error("scheduler failed", "error", err)
+		return exitError
+	}
+	logger.Info("shutting down")
+	return exitOK
+}
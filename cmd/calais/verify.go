@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype/ledger"
+)
+
+func runVerify(args []string) int {
+	fs := newFlagSet("verify", "verify [flags]",
+		"Check the price DB for malformed price directives and for duplicate prices\n"+
+			"of a symbol in the same quote on the same day. Exits with status 1 when\n"+
+			"problems are found.")
+	g := addGlobals(fs)
+	db := fs.String("db", "", "price DB to check (default ledger.price_db from the config)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	path, ok := g.priceDB(*db)
+	if !ok {
+		return exitError
+	}
+
+	entries, errs, err := ledger.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calais:", err)
+		return exitError
+	}
+	for _, err := range errs {
+		fmt.Printf("%s: %v\n", path, err)
+	}
+	dups := ledger.Duplicates(entries)
+	for _, e := range dups {
+		fmt.Printf("%s: line %d: duplicate price for %s on %s: %q\n", path, e.Line, e.Symbol, e.Day(), e.Text)
+	}
+
+	if len(errs) > 0 || len(dups) > 0 {
+		fmt.Printf("%d entries, %d malformed, %d duplicates\n", len(entries), len(errs), len(dups))
+		return exitError
+	}
+	fmt.Printf("%d entries, no problems found\n", len(entries))
+	return exitOK
+}
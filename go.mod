@@ -3,8 +3,11 @@ module git.sr.ht/~atmosx/calais
 go 1.24.4
 
 require (
+	github.com/andybalholm/cascadia v1.3.3
 	github.com/sirupsen/logrus v1.9.3
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/net v0.33.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+require golang.org/x/sys v0.29.0 // indirect
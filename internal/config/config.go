@@ -1,44 +1,365 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
+	"git.sr.ht/~atmosx/calais/pkg/calendar"
+	"git.sr.ht/~atmosx/calais/pkg/cron"
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
 	"gopkg.in/yaml.v3"
 )
 
-type MarketstackConfig struct {
-	Key    string   `yaml:"key"`
-	Stocks []string `yaml:"stocks"`
+// Stock is a symbol to fetch with optional tags used to select it on the
+// command line. In YAML it is either a plain symbol or a mapping with symbol
+// and tags. Exchange names the market identifier code of the exchange for
+// symbols whose suffix does not tell. MaxAge replaces the staleness checks
+// for the stock, see StalenessConfig. Currency is the quote currency for
+// providers that do not report one, e.g. GBX for a London listing quoted in
+// pence.
+type Stock struct {
+	Symbol   string        `yaml:"symbol"`
+	Tags     []string      `yaml:"tags"`
+	Exchange string        `yaml:"exchange"`
+	MaxAge   time.Duration `yaml:"max_age"`
+	Currency string        `yaml:"currency"`
+}
+
+// Calendar returns the trading calendar of the exchange the stock is listed
+// on, from Exchange or else the symbol suffix.
+func (s Stock) Calendar() (*calendar.Exchange, bool) {
+	if s.Exchange != "" {
+		return calendar.Lookup(s.Exchange)
+	}
+	return calendar.ForSymbol(s.Symbol)
+}
+
+func (s *Stock) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&s.Symbol)
+	}
+	type plain Stock
+	return node.Decode((*plain)(s))
 }
 
 type Pair struct {
-	From string `yaml:"from"`
-	To   string `yaml:"to"`
+	From   string        `yaml:"from"`
+	To     string        `yaml:"to"`
+	Tags   []string      `yaml:"tags"`
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+func (p Pair) String() string { return p.From + "/" + p.To }
+
+// ProviderConfig is a configured provider instance. Type selects a provider
+// registered in package providers and defaults to Name. The API key is given
+// directly, read from KeyFile or printed by KeyCommand. Options are decoded by
+// the provider itself.
+type ProviderConfig struct {
+	Name       string    `yaml:"name"`
+	Type       string    `yaml:"type"`
+	Key        string    `yaml:"key"`
+	KeyFile    string    `yaml:"key_file"`
+	KeyCommand string    `yaml:"key_command"`
+	Stocks     []Stock   `yaml:"stocks"`
+	Pairs      []Pair    `yaml:"pairs"`
+	Options    yaml.Node `yaml:"options"`
+
+	// node and origins locate the instance for error messages.
+	node    *yaml.Node
+	origins *origins
+}
+
+// Position returns where the instance is defined, if it was loaded from a
+// file.
+func (p ProviderConfig) Position() Position {
+	if p.node == nil {
+		return Position{}
+	}
+	return p.origins.pos(p.node)
+}
+
+// OptionPosition returns where the option at path, as in
+// providers.OptionError, is defined. For a missing option it is the closest
+// enclosing one, or the instance.
+func (p ProviderConfig) OptionPosition(path ...interface{}) Position {
+	if p.node == nil {
+		return Position{}
+	}
+	for i := len(path); i > 0; i-- {
+		if n := child(&p.Options, path[:i]...); n != nil {
+			return p.origins.pos(n)
+		}
+	}
+	return p.Position()
+}
+
+// ProviderType returns the registered type of the instance.
+func (p ProviderConfig) ProviderType() string {
+	if p.Type != "" {
+		return p.Type
+	}
+	return p.Name
+}
+
+// DecodeOptions decodes the instance options into v. It leaves v untouched
+// when no options were given. Options that v has no field for are an error.
+func (p ProviderConfig) DecodeOptions(v interface{}) error {
+	if p.Options.Kind == 0 {
+		return nil
+	}
+	if errs := checkFields(p.origins, &p.Options, reflect.TypeOf(v)); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return p.Options.Decode(v)
 }
 
-type FixerConfig struct {
-	Key   string `yaml:"key"`
-	Pairs []Pair `yaml:"pairs"`
+// DerivedConfig is a price computed from other prices fetched in the same
+// run, e.g. "XAU / 31.1035". Currency is the currency of the result, by
+// default the one its inputs are in.
+type DerivedConfig struct {
+	Symbol   string   `yaml:"symbol"`
+	Expr     string   `yaml:"expr"`
+	Currency string   `yaml:"currency"`
+	Tags     []string `yaml:"tags"`
+}
+
+// Staleness policies.
+const (
+	StaleWarn = "warn"
+	StaleSkip = "skip"
+	StaleTag  = "tag"
+)
+
+// StalenessConfig enables checks for prices older than expected. A stock
+// price is stale when it predates the latest session close of the stock's
+// exchange, allowing Grace for end-of-day data to arrive, and any price is
+// stale when it is older than MaxAge. A stock or pair with its own max_age
+// is only checked against that. Policy is warn, skip or tag; without one
+// nothing is checked.
+type StalenessConfig struct {
+	Policy string        `yaml:"policy"`
+	MaxAge time.Duration `yaml:"max_age"`
+	Grace  time.Duration `yaml:"grace"`
+}
+
+// Outlier actions.
+const (
+	OutlierQuarantine = "quarantine"
+	OutlierReject     = "reject"
+)
+
+// OutlierConfig enables checks of each fetched price against the latest
+// price of the symbol in the price DB. A price that moved by more than
+// MaxChange, a fraction such as 0.5 for 50%, is an outlier. Action is
+// quarantine, the default, to write outliers to ledger.quarantine for calais
+// approve, or reject to drop them.
+type OutlierConfig struct {
+	MaxChange float64 `yaml:"max_change"`
+	Action    string  `yaml:"action"`
+}
+
+// CacheConfig configures the on-disk cache of provider HTTP responses. TTL
+// defaults to an hour and Dir to ~/.cache/calais/http.
+type CacheConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	TTL      time.Duration `yaml:"ttl"`
+	Dir      string        `yaml:"dir"`
+}
+
+// StoreConfig enables the price history store at Path, which keeps every
+// price written to the price DB so that price files can be regenerated from
+// it with calais regenerate.
+type StoreConfig struct {
+	Path string `yaml:"path"`
 }
 
 type LedgerConfig struct {
+	// PriceDB defaults to the price DB ledger reads, see defaultPriceDB.
 	PriceDB string `yaml:"price_db"`
+	// Dedup replaces an existing price of the same symbol and day instead
+	// of appending a second one.
+	Dedup bool `yaml:"dedup"`
+	// Quarantine holds the outliers awaiting approval. It defaults to
+	// PriceDB with a .quarantine suffix.
+	Quarantine string `yaml:"quarantine"`
+	// Currency is the ISO 4217 code of the reporting currency stock prices
+	// are converted into before they are written. Empty keeps the currency
+	// each price is quoted in.
+	Currency string `yaml:"currency"`
+	// Timezone is the time zone prices are written in, local time by
+	// default. End-of-day prices keep their date.
+	Timezone string `yaml:"timezone"`
+	// Dates is timestamp, the default, to write the time of day of every
+	// price, date to write only dates, or auto to write only the dates of
+	// end-of-day prices.
+	Dates doctype.Dates `yaml:"dates"`
+
+	// priceDBSource names where a default PriceDB came from, and
+	// priceDBPos where PriceDB was set otherwise.
+	priceDBSource string
+	priceDBPos    Position
 }
 
-type Config struct {
-	Marketstack MarketstackConfig `yaml:"marketstack"`
-	Fixer       FixerConfig       `yaml:"fixer"`
-	Ledger      LedgerConfig      `yaml:"ledger"`
+// Location returns the time zone prices are written in.
+func (l LedgerConfig) Location() (*time.Location, error) {
+	if l.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(l.Timezone)
 }
 
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+// ScheduleConfig runs the entries it selects on a cron schedule in calais
+// serve. The selection works like the command line filters; an empty one
+// selects everything. Each run starts after a random delay of up to Jitter.
+// With Exchange set, runs are skipped on days that exchange is closed.
+type ScheduleConfig struct {
+	Name      string        `yaml:"name"`
+	Cron      string        `yaml:"cron"`
+	Timezone  string        `yaml:"timezone"`
+	Jitter    time.Duration `yaml:"jitter"`
+	Exchange  string        `yaml:"exchange"`
+	Symbols   []string      `yaml:"symbols"`
+	Pairs     []string      `yaml:"pairs"`
+	Providers []string      `yaml:"providers"`
+	Tags      []string      `yaml:"tags"`
+}
+
+// Location returns the time zone of the schedule, local time by default.
+func (s ScheduleConfig) Location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+// Schedule parses the cron expression in the schedule's time zone.
+func (s ScheduleConfig) Schedule() (*cron.Schedule, error) {
+	loc, err := s.Location()
 	if err != nil {
 		return nil, err
 	}
+	return cron.ParseInLocation(s.Cron, loc)
+}
+
+type Config struct {
+	Providers []ProviderConfig `yaml:"providers"`
+	Derived   []DerivedConfig  `yaml:"derived"`
+	Schedules []ScheduleConfig `yaml:"schedules"`
+	Staleness StalenessConfig  `yaml:"staleness"`
+	Outliers  OutlierConfig    `yaml:"outliers"`
+	Cache     CacheConfig      `yaml:"cache"`
+	Store     StoreConfig      `yaml:"store"`
+	Ledger    LedgerConfig     `yaml:"ledger"`
+}
+
+// legacyConfig holds the provider sections used before providers were
+// listed as instances. They are still accepted and converted.
+type legacyConfig struct {
+	Marketstack *struct {
+		Key    string  `yaml:"key"`
+		Stocks []Stock `yaml:"stocks"`
+	} `yaml:"marketstack"`
+	Fixer *struct {
+		Key   string `yaml:"key"`
+		Pairs []Pair `yaml:"pairs"`
+	} `yaml:"fixer"`
+}
+
+// LoadConfig reads the configuration from paths. Later files override
+// earlier ones as described in mergeDocs, so that a personal file can be
+// layered over a shared one. ${NAME} references to environment variables are
+// expanded in all values and provider keys are read from their key_file or
+// key_command. Unknown fields and invalid values are reported with their
+// position in the file.
+func LoadConfig(paths ...string) (*Config, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no configuration file given")
+	}
+	o := newOrigins()
+	var doc yaml.Node
+	for i, path := range paths {
+		layer, err := readLayer(path, o)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			doc = *layer
+		} else {
+			mergeDocs(&doc, layer)
+		}
+	}
+
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.Join(paths, ", "), err)
+	}
+	for i := range cfg.Providers {
+		cfg.Providers[i].node = child(&doc, "providers", i)
+	}
+
+	var legacy legacyConfig
+	if err := doc.Decode(&legacy); err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.Join(paths, ", "), err)
+	}
+	if m := legacy.Marketstack; m != nil && len(m.Stocks) > 0 {
+		cfg.Providers = append(cfg.Providers, ProviderConfig{Name: "marketstack", Key: m.Key, Stocks: m.Stocks, node: child(&doc, "marketstack")})
+	}
+	if f := legacy.Fixer; f != nil && f.Key != "" {
+		cfg.Providers = append(cfg.Providers, ProviderConfig{Name: "fixer", Key: f.Key, Pairs: f.Pairs, node: child(&doc, "fixer")})
+	}
+
+	var errs []error
+	for i := range cfg.Providers {
+		p := &cfg.Providers[i]
+		p.origins = o
+		// Relative key files are resolved next to the file naming them.
+		dir := filepath.Dir(o.pos(orNode(child(p.node, "key_file"), p.node)).File)
+		if err := resolveKey(p, dir); err != nil {
+			errs = append(errs, &Error{Position: p.Position(), Msg: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if cfg.Ledger.PriceDB == "" {
+		db, source, err := defaultPriceDB()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Ledger.PriceDB, cfg.Ledger.priceDBSource = db, source
+	}
+	if cfg.Ledger.Quarantine == "" && cfg.Ledger.PriceDB != "" {
+		cfg.Ledger.Quarantine = cfg.Ledger.PriceDB + ".quarantine"
+	}
+	if err := validate(&cfg, &doc, o); err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
+
+// readLayer reads a single configuration file, expands environment variables
+// and checks it for unknown fields.
+func readLayer(path string, o *origins) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := expandEnv(path, &doc); err != nil {
+		return nil, err
+	}
+	o.add(path, &doc)
+	if errs := checkFields(o, &doc, reflect.TypeOf(Config{}), reflect.TypeOf(legacyConfig{})); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &doc, nil
+}
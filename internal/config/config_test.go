@@ -3,58 +3,218 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
 )
 
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("could not create temp config: %v", err)
+	}
+	return path
+}
+
 func TestLoadConfig_Success(t *testing.T) {
 	yaml := `
-marketstack:
-  key: "test-ms-key"
-  stocks:
-    - AAPL
-    - MSFT
+providers:
+  - name: marketstack
+    key: "test-ms-key"
+    stocks:
+      - AAPL
+      - { symbol: MSFT, tags: [us, tech], max_age: 72h }
 
-fixer:
-  key: "test-fixer-key"
-  pairs:
-    - { from: "EUR", to: "USD" }
-    - { from: "GBP", to: "USD" }
+  - name: funds
+    type: exec
+    stocks: [FUND1]
+    options:
+      command: ["/usr/local/bin/nav", "--quiet"]
+      currency: EUR
+
+  - name: fixer
+    key: "test-fixer-key"
+    pairs:
+      - { from: "EUR", to: "USD" }
+      - { from: "GBP", to: "USD", tags: [fx] }
+
+derived:
+  - { symbol: GOLD_G, expr: "XAU / 31.1035" }
+
+schedules:
+  - name: us-close
+    cron: "15 16 * * mon-fri"
+    timezone: America/New_York
+    jitter: 2m
+    tags: [us]
+
+staleness:
+  policy: tag
+  max_age: 96h
+  grace: 2h
+
+outliers:
+  max_change: 0.5
+
+cache:
+  ttl: 30m
+  dir: /tmp/calais-cache
 
 ledger:
   price_db: "/tmp/prices.db"
+  dedup: true
+  currency: EUR
+  timezone: Europe/Athens
+  dates: auto
 `
-	path := filepath.Join(t.TempDir(), "config.yaml")
-	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
-		t.Fatalf("could not create temp config: %v", err)
-	}
-
-	cfg, err := LoadConfig(path)
+	cfg, err := LoadConfig(writeConfig(t, yaml))
 	if err != nil {
 		t.Fatalf("LoadConfig failed: %v", err)
 	}
 
+	if len(cfg.Providers) != 3 {
+		t.Fatalf("expected 3 providers, got %d", len(cfg.Providers))
+	}
+
 	// marketstack
-	if cfg.Marketstack.Key != "test-ms-key" {
-		t.Errorf("expected Marketstack.Key 'test-ms-key', got %q", cfg.Marketstack.Key)
+	ms := cfg.Providers[0]
+	if ms.ProviderType() != "marketstack" || ms.Key != "test-ms-key" {
+		t.Errorf("unexpected marketstack instance: %+v", ms)
+	}
+	wantStocks := []Stock{{Symbol: "AAPL"}, {Symbol: "MSFT", Tags: []string{"us", "tech"}, MaxAge: 72 * time.Hour}}
+	if !reflect.DeepEqual(ms.Stocks, wantStocks) {
+		t.Errorf("unexpected marketstack stocks: %v", ms.Stocks)
+	}
+
+	// exec with options
+	funds := cfg.Providers[1]
+	if funds.Name != "funds" || funds.ProviderType() != "exec" {
+		t.Errorf("unexpected exec instance: %+v", funds)
+	}
+	var options struct {
+		Command  []string `yaml:"command"`
+		Currency string   `yaml:"currency"`
+	}
+	if err := funds.DecodeOptions(&options); err != nil {
+		t.Fatalf("DecodeOptions: %v", err)
 	}
-	if len(cfg.Marketstack.Stocks) != 2 || cfg.Marketstack.Stocks[0] != "AAPL" || cfg.Marketstack.Stocks[1] != "MSFT" {
-		t.Errorf("unexpected Marketstack.Stocks: %v", cfg.Marketstack.Stocks)
+	if len(options.Command) != 2 || options.Currency != "EUR" {
+		t.Errorf("unexpected exec options: %+v", options)
 	}
 
 	// fixer
-	if cfg.Fixer.Key != "test-fixer-key" {
-		t.Errorf("expected Fixer.Key 'test-fixer-key', got %q", cfg.Fixer.Key)
+	fx := cfg.Providers[2]
+	if fx.Key != "test-fixer-key" {
+		t.Errorf("expected fixer key 'test-fixer-key', got %q", fx.Key)
+	}
+	if len(fx.Pairs) != 2 {
+		t.Errorf("expected 2 currency pairs, got %d", len(fx.Pairs))
+	}
+	wantPairs := []Pair{{From: "EUR", To: "USD"}, {From: "GBP", To: "USD", Tags: []string{"fx"}}}
+	if !reflect.DeepEqual(fx.Pairs, wantPairs) {
+		t.Errorf("unexpected fixer pairs: %v", fx.Pairs)
+	}
+	if err := fx.DecodeOptions(&options); err != nil {
+		t.Errorf("DecodeOptions without options: %v", err)
+	}
+
+	if len(cfg.Derived) != 1 || !reflect.DeepEqual(cfg.Derived[0], DerivedConfig{Symbol: "GOLD_G", Expr: "XAU / 31.1035"}) {
+		t.Errorf("unexpected Derived: %+v", cfg.Derived)
+	}
+
+	if len(cfg.Schedules) != 1 {
+		t.Fatalf("expected 1 schedule, got %+v", cfg.Schedules)
 	}
-	if len(cfg.Fixer.Pairs) != 2 {
-		t.Errorf("expected 2 currency pairs, got %d", len(cfg.Fixer.Pairs))
+	sc := cfg.Schedules[0]
+	if sc.Name != "us-close" || sc.Jitter != 2*time.Minute || !reflect.DeepEqual(sc.Tags, []string{"us"}) {
+		t.Errorf("unexpected schedule: %+v", sc)
 	}
-	if cfg.Fixer.Pairs[0] != (Pair{From: "EUR", To: "USD"}) || cfg.Fixer.Pairs[1] != (Pair{From: "GBP", To: "USD"}) {
-		t.Errorf("unexpected Fixer.Pairs: %v", cfg.Fixer.Pairs)
+	if s, err := sc.Schedule(); err != nil || s.String() != "15 16 * * mon-fri" {
+		t.Errorf("Schedule() = %v, %v", s, err)
+	}
+
+	if want := (StalenessConfig{Policy: StaleTag, MaxAge: 96 * time.Hour, Grace: 2 * time.Hour}); cfg.Staleness != want {
+		t.Errorf("unexpected Staleness: %+v", cfg.Staleness)
+	}
+
+	if cfg.Outliers.MaxChange != 0.5 || cfg.Outliers.Action != "" {
+		t.Errorf("unexpected Outliers: %+v", cfg.Outliers)
+	}
+
+	if want := (CacheConfig{TTL: 30 * time.Minute, Dir: "/tmp/calais-cache"}); cfg.Cache != want {
+		t.Errorf("unexpected Cache: %+v", cfg.Cache)
 	}
 
 	if cfg.Ledger.PriceDB != "/tmp/prices.db" {
 		t.Errorf("expected Ledger.PriceDB '/tmp/prices.db', got %q", cfg.Ledger.PriceDB)
 	}
+	if !cfg.Ledger.Dedup {
+		t.Error("expected Ledger.Dedup to be set")
+	}
+	if cfg.Ledger.Currency != "EUR" {
+		t.Errorf("unexpected Ledger.Currency %q", cfg.Ledger.Currency)
+	}
+	if loc, err := cfg.Ledger.Location(); err != nil || loc.String() != "Europe/Athens" || cfg.Ledger.Dates != doctype.DatesAuto {
+		t.Errorf("unexpected Ledger times: %v, %v, %q", loc, err, cfg.Ledger.Dates)
+	}
+	if cfg.Ledger.Quarantine != "/tmp/prices.db.quarantine" {
+		t.Errorf("unexpected Ledger.Quarantine %q", cfg.Ledger.Quarantine)
+	}
+}
+
+func TestStock_Calendar(t *testing.T) {
+	tests := []struct {
+		stock Stock
+		want  string
+	}{
+		{Stock{Symbol: "SXR8.DE"}, "XETR"},
+		{Stock{Symbol: "AAPL"}, ""},
+		{Stock{Symbol: "AAPL", Exchange: "XNYS"}, "XNYS"},
+		{Stock{Symbol: "FUND1", Exchange: "XATH"}, "XATH"},
+		{Stock{Symbol: "7203.T"}, ""},
+	}
+	for _, tt := range tests {
+		var got string
+		if e, ok := tt.stock.Calendar(); ok {
+			got = e.Code
+		}
+		if got != tt.want {
+			t.Errorf("%+v: Calendar() = %q, want %q", tt.stock, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_Legacy(t *testing.T) {
+	yaml := `
+marketstack:
+  key: "test-ms-key"
+  stocks:
+    - AAPL
+
+fixer:
+  key: "test-fixer-key"
+  pairs:
+    - { from: "EUR", to: "USD" }
+
+ledger:
+  price_db: "/tmp/prices.db"
+`
+	cfg, err := LoadConfig(writeConfig(t, yaml))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.Providers) != 2 {
+		t.Fatalf("expected 2 providers, got %d", len(cfg.Providers))
+	}
+	if p := cfg.Providers[0]; p.ProviderType() != "marketstack" || p.Key != "test-ms-key" || len(p.Stocks) != 1 || p.Stocks[0].Symbol != "AAPL" {
+		t.Errorf("unexpected marketstack instance: %+v", p)
+	}
+	if p := cfg.Providers[1]; p.ProviderType() != "fixer" || p.Key != "test-fixer-key" || len(p.Pairs) != 1 {
+		t.Errorf("unexpected fixer instance: %+v", p)
+	}
 }
 
 func TestLoadConfig_FileNotFound(t *testing.T) {
@@ -72,12 +232,7 @@ marketstack:
 ledger:
   price_db: "/tmp/prices.db"
 `
-	path := filepath.Join(t.TempDir(), "malformed.yaml")
-	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
-		t.Fatalf("could not create temp config: %v", err)
-	}
-
-	_, err := LoadConfig(path)
+	_, err := LoadConfig(writeConfig(t, yaml))
 	if err == nil {
 		t.Error("expected YAML unmarshal error, got nil")
 	}
@@ -0,0 +1,19 @@
+package config
+
+import "testing"
+
+func TestIsCurrency(t *testing.T) {
+	for _, code := range []string{"EUR", "USD", "GBP", "XAU", "ZWL", "AED"} {
+		if !IsCurrency(code) {
+			t.Errorf("IsCurrency(%q) = false", code)
+		}
+	}
+	for _, code := range []string{"", "eur", "EURO", "ABC", "BTC"} {
+		if IsCurrency(code) {
+			t.Errorf("IsCurrency(%q) = true", code)
+		}
+	}
+	if len(currencies) < 170 {
+		t.Errorf("expected the full code list, got %d codes", len(currencies))
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SearchPath returns the locations searched for the configuration when none
+// is given, in order: $XDG_CONFIG_HOME/calais (~/.config/calais when unset),
+// ~/.calais and /etc/calais.
+func SearchPath() []string {
+	var paths []string
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	if xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "calais", "config.yaml"))
+	}
+	if home != "" {
+		paths = append(paths, filepath.Join(home, ".calais", "config.yaml"))
+	}
+	return append(paths, "/etc/calais/config.yaml")
+}
+
+// Find returns the configuration files to load: the files listed in
+// $CALAIS_CONFIG, separated like $PATH, or else the first file of SearchPath
+// that exists.
+func Find() ([]string, error) {
+	if env := os.Getenv("CALAIS_CONFIG"); env != "" {
+		return filepath.SplitList(env), nil
+	}
+	paths := SearchPath()
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return []string{p}, nil
+		}
+	}
+	return nil, fmt.Errorf("no configuration file found, searched %s", strings.Join(paths, ", "))
+}
+
+// origins records the file each node of a merged document was read from.
+type origins struct {
+	files map[*yaml.Node]string
+	// last is the last file read, named in errors about missing values.
+	last string
+}
+
+func newOrigins() *origins {
+	return &origins{files: map[*yaml.Node]string{}}
+}
+
+func (o *origins) add(file string, n *yaml.Node) {
+	o.files[n] = file
+	for _, c := range n.Content {
+		o.add(file, c)
+	}
+	o.last = file
+}
+
+// pos returns the position of n, or just the last file read when n is nil.
+func (o *origins) pos(n *yaml.Node) Position {
+	if o == nil {
+		return Position{}
+	}
+	if n == nil {
+		return Position{File: o.last}
+	}
+	return Position{File: o.files[n], Line: n.Line, Column: n.Column}
+}
+
+// keyedList describes a list whose items are merged by a key field instead
+// of being replaced as a whole.
+type keyedList struct {
+	key string
+	// exclusive are fields of an item of which an override sets at most one,
+	// dropping the others from the item it overrides.
+	exclusive []string
+}
+
+var keyedLists = map[string]keyedList{
+	"providers": {key: "name", exclusive: []string{"key", "key_file", "key_command"}},
+	"derived":   {key: "symbol"},
+	"schedules": {key: "name"},
+}
+
+// mergeDocs merges the document src into dst. Mappings are merged key by
+// key, providers and schedules by name and derived prices by symbol; any
+// other value in src, including lists such as stocks and pairs, replaces the
+// one in dst.
+func mergeDocs(dst, src *yaml.Node) {
+	switch {
+	case len(src.Content) == 0:
+	case len(dst.Content) == 0:
+		dst.Content = src.Content
+	default:
+		mergeMapping(dst.Content[0], src.Content[0], true)
+	}
+}
+
+func mergeMapping(dst, src *yaml.Node, top bool) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		*dst = *src
+		return
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		k, v := src.Content[i], src.Content[i+1]
+		j := indexOf(dst, k.Value)
+		if j < 0 {
+			dst.Content = append(dst.Content, k, v)
+			continue
+		}
+		d := dst.Content[j+1]
+		list, keyed := keyedLists[k.Value]
+		switch {
+		case top && keyed && d.Kind == yaml.SequenceNode && v.Kind == yaml.SequenceNode:
+			mergeList(d, v, list)
+		case d.Kind == yaml.MappingNode && v.Kind == yaml.MappingNode:
+			mergeMapping(d, v, false)
+		default:
+			dst.Content[j+1] = v
+		}
+	}
+}
+
+func mergeList(dst, src *yaml.Node, list keyedList) {
+	for _, item := range src.Content {
+		target := findItem(dst, item, list.key)
+		if target == nil {
+			dst.Content = append(dst.Content, item)
+			continue
+		}
+		for _, f := range list.exclusive {
+			if indexOf(item, f) >= 0 {
+				for _, g := range list.exclusive {
+					removeKey(target, g)
+				}
+				break
+			}
+		}
+		mergeMapping(target, item, false)
+	}
+}
+
+// findItem returns the mapping in the sequence list whose key field equals
+// that of item.
+func findItem(list, item *yaml.Node, key string) *yaml.Node {
+	name := child(item, key)
+	if name == nil || item.Kind != yaml.MappingNode {
+		return nil
+	}
+	for _, c := range list.Content {
+		if n := child(c, key); c.Kind == yaml.MappingNode && n != nil && n.Value == name.Value {
+			return c
+		}
+	}
+	return nil
+}
+
+// indexOf returns the index of key in the mapping n, or -1.
+func indexOf(n *yaml.Node, key string) int {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func removeKey(n *yaml.Node, key string) {
+	if i := indexOf(n, key); i >= 0 {
+		n.Content = append(n.Content[:i], n.Content[i+2:]...)
+	}
+}
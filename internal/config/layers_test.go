@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchPath(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	want := []string{"/home/me/.config/calais/config.yaml", "/home/me/.calais/config.yaml", "/etc/calais/config.yaml"}
+	if got := SearchPath(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchPath() = %v, want %v", got, want)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if got := SearchPath(); got[0] != "/xdg/calais/config.yaml" {
+		t.Errorf("expected $XDG_CONFIG_HOME first, got %v", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("CALAIS_CONFIG", "")
+
+	dotCalais := filepath.Join(home, ".calais", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(dotCalais), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dotCalais, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Find(); err != nil || !reflect.DeepEqual(got, []string{dotCalais}) {
+		t.Errorf("Find() = %v, %v, want %s", got, err, dotCalais)
+	}
+
+	xdg := filepath.Join(home, ".config", "calais", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(xdg), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(xdg, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Find(); err != nil || !reflect.DeepEqual(got, []string{xdg}) {
+		t.Errorf("Find() = %v, %v, want %s", got, err, xdg)
+	}
+
+	t.Setenv("CALAIS_CONFIG", "/team.yaml"+string(os.PathListSeparator)+"/me.yaml")
+	if got, err := Find(); err != nil || !reflect.DeepEqual(got, []string{"/team.yaml", "/me.yaml"}) {
+		t.Errorf("Find() = %v, %v, want the files in $CALAIS_CONFIG", got, err)
+	}
+}
+
+func TestLoadConfig_Layers(t *testing.T) {
+	team := writeConfig(t, `
+providers:
+  - name: marketstack
+    key: team-key
+    stocks: [AAPL, MSFT]
+  - name: funds
+    type: exec
+    stocks: [FUND1]
+    options:
+      command: [nav]
+      currency: EUR
+derived:
+  - { symbol: GOLD_G, expr: "XAU / 31.1035" }
+ledger:
+  price_db: /tmp/prices.db
+`)
+	me := writeConfig(t, `
+providers:
+  - name: marketstack
+    key_file: secret
+    stocks: [VOO]
+  - name: funds
+    options:
+      currency: USD
+  - name: stooq
+    stocks: [SAP.DE]
+derived:
+  - { symbol: GOLD_G, expr: "XAU / 31.1" }
+ledger:
+  dedup: true
+`)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(me), "secret"), []byte("my-key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(team, me)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.Providers) != 3 {
+		t.Fatalf("expected 3 providers, got %+v", cfg.Providers)
+	}
+	ms := cfg.Providers[0]
+	if ms.Key != "my-key" {
+		t.Errorf("expected the key from the override's key_file, got %q", ms.Key)
+	}
+	if got := ms.Stocks; len(got) != 1 || got[0].Symbol != "VOO" {
+		t.Errorf("expected lists to be replaced, got %v", got)
+	}
+	var options struct {
+		Command  []string `yaml:"command"`
+		Currency string   `yaml:"currency"`
+	}
+	if err := cfg.Providers[1].DecodeOptions(&options); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Providers[1].ProviderType() != "exec" || len(options.Command) != 1 || options.Currency != "USD" {
+		t.Errorf("expected merged options, got type %q and %+v", cfg.Providers[1].ProviderType(), options)
+	}
+	if cfg.Providers[2].Name != "stooq" {
+		t.Errorf("expected the new provider to be appended, got %+v", cfg.Providers[2])
+	}
+	if len(cfg.Derived) != 1 || cfg.Derived[0].Expr != "XAU / 31.1" {
+		t.Errorf("expected derived prices to merge by symbol, got %+v", cfg.Derived)
+	}
+	if cfg.Ledger.PriceDB != "/tmp/prices.db" || !cfg.Ledger.Dedup {
+		t.Errorf("expected merged ledger settings, got %+v", cfg.Ledger)
+	}
+	if pos := cfg.Providers[2].Position(); pos.File != me || pos.Line != 9 {
+		t.Errorf("expected the position in the override, got %v", pos)
+	}
+}
+
+func TestLoadConfig_LayerErrorPosition(t *testing.T) {
+	team := writeConfig(t, "ledger: { price_db: /tmp/prices.db }\n")
+	me := writeConfig(t, "providers:\n  - name: fixer\n    key: abc\n    pairs: [{ from: EUR, to: EURO }]\n")
+	_, err := LoadConfig(team, me)
+	if err == nil || !strings.HasPrefix(err.Error(), me+":4:30: ") {
+		t.Errorf("expected an error in %s, got %v", me, err)
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// defaultPriceDB returns the price DB that ledger itself reads when none is
+// configured: $LEDGER_PRICE_DB, or else the --price-db option of ledger's
+// init file ($LEDGER_INIT_FILE, ~/.ledgerrc by default). source names where
+// the path came from and is empty when neither sets one.
+func defaultPriceDB() (path, source string, err error) {
+	if v := os.Getenv("LEDGER_PRICE_DB"); v != "" {
+		return expandHome(v), "LEDGER_PRICE_DB", nil
+	}
+
+	rc := os.Getenv("LEDGER_INIT_FILE")
+	if rc == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", nil
+		}
+		rc = filepath.Join(home, ".ledgerrc")
+	}
+	path, err = readLedgerrc(expandHome(rc))
+	if err != nil || path == "" {
+		return "", "", err
+	}
+	return path, rc, nil
+}
+
+// readLedgerrc returns the value of the --price-db option in the ledger init
+// file at path. A missing file is not an error.
+func readLedgerrc(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var db string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		// The name ends at the first = or whitespace, so the value may hold
+		// either.
+		i := strings.IndexFunc(line, func(r rune) bool { return r == '=' || unicode.IsSpace(r) })
+		if i < 0 || line[:i] != "--price-db" {
+			continue
+		}
+		value := line[i:]
+		if value[0] == '=' {
+			value = value[1:]
+		}
+		// Like ledger, the last occurrence wins.
+		db = expandHome(strings.Trim(strings.TrimSpace(value), `"'`))
+	}
+	return db, s.Err()
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
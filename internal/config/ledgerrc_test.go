@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_LedgerPriceDB(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LEDGER_PRICE_DB", filepath.Join(dir, "env.db"))
+	t.Setenv("LEDGER_INIT_FILE", "/non/existent/ledgerrc")
+
+	cfg, err := LoadConfig(writeConfig(t, "providers: [{ name: stooq, stocks: [AAPL] }]\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Ledger.PriceDB != filepath.Join(dir, "env.db") {
+		t.Errorf("expected the price DB from LEDGER_PRICE_DB, got %q", cfg.Ledger.PriceDB)
+	}
+
+	cfg, err = LoadConfig(writeConfig(t, "ledger: { price_db: "+filepath.Join(dir, "config.db")+" }\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Ledger.PriceDB != filepath.Join(dir, "config.db") {
+		t.Errorf("expected ledger.price_db to take precedence, got %q", cfg.Ledger.PriceDB)
+	}
+
+	t.Setenv("LEDGER_PRICE_DB", "/non/existent/prices.db")
+	if cfg, err = LoadConfig(writeConfig(t, "{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.Ledger.CheckWritable()
+	if err == nil || !strings.Contains(err.Error(), "price DB from LEDGER_PRICE_DB: cannot create /non/existent/prices.db") {
+		t.Errorf("expected an error naming LEDGER_PRICE_DB, got %v", err)
+	}
+}
+
+func TestLoadConfig_Ledgerrc(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("LEDGER_PRICE_DB", "")
+	t.Setenv("LEDGER_INIT_FILE", "")
+	rc := "--file ~/journal.ledger\n--price-db ~/old.db\n--price-db=~/prices.db\n"
+	if err := os.WriteFile(filepath.Join(home, ".ledgerrc"), []byte(rc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(writeConfig(t, "{}\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if want := filepath.Join(home, "prices.db"); cfg.Ledger.PriceDB != want {
+		t.Errorf("expected %q from ~/.ledgerrc, got %q", want, cfg.Ledger.PriceDB)
+	}
+}
+
+func TestReadLedgerrc(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ledgerrc")
+	if err := os.WriteFile(path, []byte("--strict\n--price-db \"/var/lib/ledger/prices.db\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readLedgerrc(path); err != nil || got != "/var/lib/ledger/prices.db" {
+		t.Errorf("readLedgerrc() = %q, %v", got, err)
+	}
+	for rc, want := range map[string]string{
+		"--price-db /srv/a=b/prices.db\n":   "/srv/a=b/prices.db",
+		"--price-db\t/srv/prices.db\n":      "/srv/prices.db",
+		"--price-db=/srv/a b/prices.db\n":   "/srv/a b/prices.db",
+		"--price-dbx /srv/prices.db\n":      "",
+		"  --price-db   /srv/prices.db  \n": "/srv/prices.db",
+	} {
+		if err := os.WriteFile(path, []byte(rc), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := readLedgerrc(path); err != nil || got != want {
+			t.Errorf("readLedgerrc(%q) = %q, %v, want %q", rc, got, err, want)
+		}
+	}
+	if got, err := readLedgerrc(filepath.Join(dir, "missing")); err != nil || got != "" {
+		t.Errorf("expected nothing for a missing file, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// keyCommandTimeout bounds the run time of a key_command.
+const keyCommandTimeout = 30 * time.Second
+
+// envRef matches ${NAME} and ${NAME:-default}. The bare $NAME form is not
+// expanded so that values such as the JSON path $.data[0].close are left
+// alone.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces environment variable references in every scalar value
+// of the document. Unset variables without a default are an error.
+func expandEnv(file string, node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var missing []string
+		node.Value = envRef.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			m := envRef.FindStringSubmatch(ref)
+			if v, ok := os.LookupEnv(m[1]); ok {
+				return v
+			}
+			if strings.Contains(ref, ":-") {
+				return m[2]
+			}
+			missing = append(missing, m[1])
+			return ""
+		})
+		if len(missing) > 0 {
+			return errorAt(Position{File: file, Line: node.Line, Column: node.Column}, "environment variable %s is not set", strings.Join(missing, ", "))
+		}
+		return nil
+	}
+	for i, c := range node.Content {
+		// Leave mapping keys alone.
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+		if err := expandEnv(file, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveKey fills p.Key from key_file or key_command. Relative key files are
+// looked up next to the configuration file. The key itself never appears in
+// the returned errors.
+func resolveKey(p *ProviderConfig, dir string) error {
+	var set int
+	for _, v := range []string{p.Key, p.KeyFile, p.KeyCommand} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("provider %s: only one of key, key_file and key_command may be set", p.Name)
+	}
+
+	switch {
+	case p.KeyFile != "":
+		path := p.KeyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("provider %s: key_file: %w", p.Name, err)
+		}
+		p.Key = strings.TrimSpace(string(data))
+	case p.KeyCommand != "":
+		ctx, cancel := context.WithTimeout(context.Background(), keyCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", p.KeyCommand)
+		cmd.Dir = dir
+		var stdout bytes.Buffer
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("provider %s: key_command: %w", p.Name, err)
+		}
+		// Password managers print the secret on the first line.
+		p.Key = strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+	default:
+		return nil
+	}
+
+	if p.Key == "" {
+		return fmt.Errorf("provider %s: key is empty", p.Name)
+	}
+	return nil
+}
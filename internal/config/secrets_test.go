@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_EnvExpansion(t *testing.T) {
+	t.Setenv("CALAIS_TEST_KEY", "env-key")
+	dir := t.TempDir()
+	t.Setenv("CALAIS_TEST_DIR", dir)
+	yaml := `
+providers:
+  - name: marketstack
+    key: "${CALAIS_TEST_KEY}"
+    stocks: [AAPL]
+  - name: prices
+    type: httpjson
+    options:
+      price: "$.data[0].close"
+      url: "https://example.com/${CALAIS_TEST_UNSET:-v1}/{symbol}"
+ledger:
+  price_db: "${CALAIS_TEST_DIR}/prices.db"
+`
+	cfg, err := LoadConfig(writeConfig(t, yaml))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Providers[0].Key != "env-key" {
+		t.Errorf("expected key from environment, got %q", cfg.Providers[0].Key)
+	}
+	if cfg.Ledger.PriceDB != dir+"/prices.db" {
+		t.Errorf("unexpected price DB %q", cfg.Ledger.PriceDB)
+	}
+	var options struct {
+		Price string `yaml:"price"`
+		URL   string `yaml:"url"`
+	}
+	if err := cfg.Providers[1].DecodeOptions(&options); err != nil {
+		t.Fatal(err)
+	}
+	if options.Price != "$.data[0].close" || options.URL != "https://example.com/v1/{symbol}" {
+		t.Errorf("unexpected options: %+v", options)
+	}
+}
+
+func TestLoadConfig_EnvUnset(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, `ledger: { price_db: "${CALAIS_TEST_UNSET}" }`))
+	if err == nil || !strings.Contains(err.Error(), "CALAIS_TEST_UNSET") {
+		t.Errorf("expected error naming the variable, got %v", err)
+	}
+}
+
+func TestLoadConfig_KeyFile(t *testing.T) {
+	path := writeConfig(t, `
+providers:
+  - name: relative
+    key_file: secret
+  - name: command
+    key_command: "printf 'cmd-key\nsecond line\n'"
+ledger:
+  price_db: "/tmp/prices.db"
+`)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "secret"), []byte("file-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Providers[0].Key != "file-key" {
+		t.Errorf("expected key from file, got %q", cfg.Providers[0].Key)
+	}
+	if cfg.Providers[1].Key != "cmd-key" {
+		t.Errorf("expected key from command, got %q", cfg.Providers[1].Key)
+	}
+}
+
+func TestLoadConfig_KeyErrors(t *testing.T) {
+	tests := map[string]string{
+		"conflict":       `providers: [{ name: ms, key: abc, key_file: /tmp/x }]`,
+		"missing file":   `providers: [{ name: ms, key_file: /non/existent/secret }]`,
+		"failed command": `providers: [{ name: ms, key_command: "echo topsecret; exit 1" }]`,
+		"empty command":  `providers: [{ name: ms, key_command: "true" }]`,
+	}
+	for name, yaml := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, yaml))
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if strings.Contains(err.Error(), "topsecret") {
+				t.Errorf("error leaks the key: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"git.sr.ht/~atmosx/calais/pkg/calendar"
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/expr"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a location in a configuration file.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Error is a configuration error at a position in a file.
+type Error struct {
+	Position
+	Msg string
+}
+
+func (e *Error) Error() string { return e.Position.String() + ": " + e.Msg }
+
+func errorAt(pos Position, format string, args ...interface{}) error {
+	return &Error{Position: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// checkFields reports every mapping key in n that has no field in the types
+// ts, which are merged. It follows the yaml struct tags into nested structs,
+// slices and maps. Values of the wrong kind are left to the decoder.
+func checkFields(o *origins, n *yaml.Node, ts ...reflect.Type) []error {
+	if n == nil {
+		return nil
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil
+		}
+		return checkFields(o, n.Content[0], ts...)
+	case yaml.AliasNode:
+		return checkFields(o, n.Alias, ts...)
+	}
+
+	var fields map[string]reflect.Type
+	var elem reflect.Type
+	for _, t := range ts {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			if t == reflect.TypeOf(yaml.Node{}) {
+				// Decoded later by whoever owns the value.
+				return nil
+			}
+			if fields == nil {
+				fields = map[string]reflect.Type{}
+			}
+			structFields(t, fields)
+		case reflect.Slice, reflect.Array, reflect.Map:
+			elem = t.Elem()
+		}
+	}
+
+	var errs []error
+	switch {
+	case n.Kind == yaml.MappingNode && elem != nil:
+		for i := 1; i < len(n.Content); i += 2 {
+			errs = append(errs, checkFields(o, n.Content[i], elem)...)
+		}
+	case n.Kind == yaml.MappingNode && fields != nil:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			t, ok := fields[k.Value]
+			if !ok {
+				errs = append(errs, unknownField(o, k, fields))
+				continue
+			}
+			errs = append(errs, checkFields(o, v, t)...)
+		}
+	case n.Kind == yaml.SequenceNode && elem != nil:
+		for _, c := range n.Content {
+			errs = append(errs, checkFields(o, c, elem)...)
+		}
+	}
+	return errs
+}
+
+// structFields adds the yaml names of the fields of t, including inlined
+// structs, to fields.
+func structFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			structFields(f.Type, fields)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+}
+
+func unknownField(o *origins, k *yaml.Node, fields map[string]reflect.Type) error {
+	var best string
+	bestDist := 3
+	for name := range fields {
+		if d := distance(k.Value, name); d < bestDist || d == bestDist && name < best {
+			best, bestDist = name, d
+		}
+	}
+	if best != "" {
+		return errorAt(o.pos(k), "unknown field %q, did you mean %q?", k.Value, best)
+	}
+	if len(fields) == 0 {
+		return errorAt(o.pos(k), "unknown field %q, no fields are allowed here", k.Value)
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return errorAt(o.pos(k), "unknown field %q, expected one of %s", k.Value, strings.Join(names, ", "))
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// child returns the value of key in the mapping n, or the item at index in
+// the sequence n, following path. It returns nil when the path does not
+// exist.
+func child(n *yaml.Node, path ...interface{}) *yaml.Node {
+	for _, p := range path {
+		if n == nil {
+			return nil
+		}
+		if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+			n = n.Content[0]
+		}
+		var next *yaml.Node
+		switch p := p.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					if n.Content[i].Value == p {
+						next = n.Content[i+1]
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && p < len(n.Content) {
+				next = n.Content[p]
+			}
+		}
+		n = next
+	}
+	return n
+}
+
+// orNode returns n, or fallback when n is nil.
+func orNode(n, fallback *yaml.Node) *yaml.Node {
+	if n != nil {
+		return n
+	}
+	return fallback
+}
+
+// validate checks the decoded configuration for mistakes the decoder cannot
+// see. doc is the document cfg was decoded from.
+func validate(cfg *Config, doc *yaml.Node, o *origins) error {
+	var errs []error
+	names := map[string]bool{}
+	for _, p := range cfg.Providers {
+		n := p.node
+		if p.Name == "" {
+			errs = append(errs, errorAt(o.pos(n), "provider has no name"))
+		} else if names[p.Name] {
+			errs = append(errs, errorAt(o.pos(orNode(child(n, "name"), n)), "duplicate provider name %q", p.Name))
+		}
+		names[p.Name] = true
+		if k := child(n, "key"); k != nil && p.Key == "" {
+			errs = append(errs, errorAt(o.pos(k), "provider %s: key is empty", p.Name))
+		}
+		for i, s := range p.Stocks {
+			if s.Symbol == "" {
+				errs = append(errs, errorAt(o.pos(orNode(child(n, "stocks", i), n)), "provider %s: stock has no symbol", p.Name))
+			}
+			if _, ok := calendar.Lookup(s.Exchange); s.Exchange != "" && !ok {
+				errs = append(errs, errorAt(o.pos(orNode(child(n, "stocks", i, "exchange"), n)), "provider %s: unknown exchange %q", p.Name, s.Exchange))
+			}
+			if s.Currency != "" && !IsCurrency(s.Currency) && !providers.IsMinorUnit(s.Currency) {
+				at := orNode(child(n, "stocks", i, "currency"), n)
+				errs = append(errs, errorAt(o.pos(at), "provider %s: currency: %q is neither an ISO 4217 code nor a minor unit such as GBX", p.Name, s.Currency))
+			}
+		}
+		for i, pair := range p.Pairs {
+			for _, side := range []struct{ key, code string }{{"from", pair.From}, {"to", pair.To}} {
+				if !IsCurrency(side.code) {
+					at := orNode(child(n, "pairs", i, side.key), orNode(child(n, "pairs", i), n))
+					errs = append(errs, errorAt(o.pos(at), "provider %s: %s: %q is not an ISO 4217 currency code", p.Name, side.key, side.code))
+				}
+			}
+		}
+	}
+
+	// A base currency names the rate of its pair in expressions, unless it
+	// is the base of several pairs.
+	pairs := map[string][]string{}
+	for _, p := range cfg.Providers {
+		for _, pair := range p.Pairs {
+			if !slices.Contains(pairs[pair.From], pair.String()) {
+				pairs[pair.From] = append(pairs[pair.From], pair.String())
+			}
+		}
+	}
+	for i, d := range cfg.Derived {
+		n := child(doc, "derived", i)
+		if d.Symbol == "" {
+			errs = append(errs, errorAt(o.pos(n), "derived price has no symbol"))
+		}
+		if e, err := expr.Parse(d.Expr); err != nil {
+			errs = append(errs, errorAt(o.pos(orNode(child(n, "expr"), n)), "derived %s: %v", d.Symbol, err))
+		} else {
+			for _, v := range e.Vars() {
+				if len(pairs[v]) > 1 {
+					errs = append(errs, errorAt(o.pos(orNode(child(n, "expr"), n)), "derived %s: %s is the base of %s, write the pair in quotes, e.g. \"%s\"",
+						d.Symbol, v, strings.Join(pairs[v], " and "), pairs[v][0]))
+				}
+			}
+		}
+		if d.Currency != "" && !IsCurrency(d.Currency) {
+			errs = append(errs, errorAt(o.pos(orNode(child(n, "currency"), n)), "derived %s: currency: %q is not an ISO 4217 currency code", d.Symbol, d.Currency))
+		}
+	}
+
+	schedules := map[string]bool{}
+	for i, sc := range cfg.Schedules {
+		n := child(doc, "schedules", i)
+		if sc.Name == "" {
+			errs = append(errs, errorAt(o.pos(n), "schedule has no name"))
+		} else if schedules[sc.Name] {
+			errs = append(errs, errorAt(o.pos(orNode(child(n, "name"), n)), "duplicate schedule name %q", sc.Name))
+		}
+		schedules[sc.Name] = true
+		if _, err := sc.Location(); err != nil {
+			errs = append(errs, errorAt(o.pos(orNode(child(n, "timezone"), n)), "schedule %s: %v", sc.Name, err))
+		} else if _, err := sc.Schedule(); err != nil {
+			errs = append(errs, errorAt(o.pos(orNode(child(n, "cron"), n)), "schedule %s: %v", sc.Name, err))
+		}
+		if _, ok := calendar.Lookup(sc.Exchange); sc.Exchange != "" && !ok {
+			errs = append(errs, errorAt(o.pos(orNode(child(n, "exchange"), n)), "schedule %s: unknown exchange %q", sc.Name, sc.Exchange))
+		}
+		if sc.Jitter < 0 {
+			errs = append(errs, errorAt(o.pos(orNode(child(n, "jitter"), n)), "schedule %s: negative jitter", sc.Name))
+		}
+	}
+
+	switch cfg.Staleness.Policy {
+	case "", StaleWarn, StaleSkip, StaleTag:
+	default:
+		n := child(doc, "staleness", "policy")
+		errs = append(errs, errorAt(o.pos(n), "staleness.policy: unknown policy %q, expected warn, skip or tag", cfg.Staleness.Policy))
+	}
+
+	if cfg.Outliers.MaxChange < 0 {
+		errs = append(errs, errorAt(o.pos(child(doc, "outliers", "max_change")), "outliers.max_change: must not be negative"))
+	}
+	switch cfg.Outliers.Action {
+	case "", OutlierQuarantine, OutlierReject:
+	default:
+		n := child(doc, "outliers", "action")
+		errs = append(errs, errorAt(o.pos(n), "outliers.action: unknown action %q, expected quarantine or reject", cfg.Outliers.Action))
+	}
+
+	if cfg.Cache.TTL < 0 {
+		errs = append(errs, errorAt(o.pos(child(doc, "cache", "ttl")), "cache.ttl: must not be negative"))
+	}
+
+	if c := cfg.Ledger.Currency; c != "" && !IsCurrency(c) {
+		errs = append(errs, errorAt(o.pos(child(doc, "ledger", "currency")), "ledger.currency: %q is not an ISO 4217 currency code", c))
+	}
+
+	if _, err := cfg.Ledger.Location(); err != nil {
+		errs = append(errs, errorAt(o.pos(child(doc, "ledger", "timezone")), "ledger.timezone: %v", err))
+	}
+	switch cfg.Ledger.Dates {
+	case "", doctype.Timestamps, doctype.DatesOnly, doctype.DatesAuto:
+	default:
+		n := child(doc, "ledger", "dates")
+		errs = append(errs, errorAt(o.pos(n), "ledger.dates: unknown policy %q, expected timestamp, date or auto", cfg.Ledger.Dates))
+	}
+
+	if cfg.Ledger.PriceDB == "" {
+		errs = append(errs, errorAt(o.pos(child(doc, "ledger")), "ledger.price_db is not set, nor is LEDGER_PRICE_DB or --price-db in ~/.ledgerrc"))
+	}
+	cfg.Ledger.priceDBPos = o.pos(child(doc, "ledger", "price_db"))
+	return errors.Join(errs...)
+}
+
+// CheckWritable returns an error, citing where the price DB was configured,
+// when it cannot be written. Only the commands that write to the price DB
+// check it, so that the others work with a read-only one.
+func (l LedgerConfig) CheckWritable() error {
+	err := checkWritable(l.PriceDB)
+	switch {
+	case err == nil:
+		return nil
+	case l.priceDBSource != "":
+		return fmt.Errorf("price DB from %s: %w", l.priceDBSource, err)
+	case l.priceDBPos.File == "":
+		return fmt.Errorf("ledger.price_db: %w", err)
+	}
+	return &Error{Position: l.priceDBPos, Msg: "ledger.price_db: " + err.Error()}
+}
+
+// checkWritable returns an error when path cannot be written. It leaves no
+// file behind.
+func checkWritable(path string) error {
+	if fi, err := os.Stat(path); err == nil {
+		if fi.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".calais-check-*")
+	if err != nil {
+		return fmt.Errorf("cannot create %s: %w", path, errors.Unwrap(err))
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
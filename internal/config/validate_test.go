@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	t.Setenv("LEDGER_PRICE_DB", "")
+	t.Setenv("LEDGER_INIT_FILE", "/non/existent/ledgerrc")
+	tests := map[string]struct {
+		yaml string
+		want string
+	}{
+		"unknown top-level field": {
+			yaml: "marketstak:\n  key: abc\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:1:1: unknown field "marketstak", did you mean "marketstack"?`,
+		},
+		"unknown provider field": {
+			yaml: "providers:\n  - name: marketstack\n    stoks: [AAPL]\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:3:5: unknown field "stoks", did you mean "stocks"?`,
+		},
+		"unknown ledger field": {
+			yaml: "ledger:\n  price_db: /tmp/prices.db\n  zzzzzz: true\n",
+			want: `:3:3: unknown field "zzzzzz", expected one of currency, dates, dedup, price_db, quarantine, timezone`,
+		},
+		"missing price db": {
+			yaml: "providers: [{ name: stooq, stocks: [AAPL] }]\n",
+			want: ": ledger.price_db is not set",
+		},
+		"invalid currency": {
+			yaml: "providers:\n  - name: fixer\n    key: abc\n    pairs:\n      - { from: EUR, to: USX }\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:5:26: provider fixer: to: "USX" is not an ISO 4217 currency code`,
+		},
+		"empty key": {
+			yaml: "providers:\n  - name: fixer\n    key: \"${CALAIS_TEST_UNSET:-}\"\nledger: { price_db: /tmp/prices.db }\n",
+			want: ":3:10: provider fixer: key is empty",
+		},
+		"duplicate provider": {
+			yaml: "providers:\n  - name: stooq\n  - name: stooq\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:3:11: duplicate provider name "stooq"`,
+		},
+		"invalid derived": {
+			yaml: "derived:\n  - { symbol: GOLD_G, expr: \"XAU /\" }\nledger: { price_db: /tmp/prices.db }\n",
+			want: ":2:29: derived GOLD_G:",
+		},
+		"invalid derived currency": {
+			yaml: "derived:\n  - { symbol: GOLD_G, expr: XAU / 31.1035, currency: euro }\nledger: { price_db: /tmp/prices.db }\n",
+			want: ":2:54: derived GOLD_G: currency: \"euro\" is not an ISO 4217 currency code",
+		},
+		"ambiguous pair base": {
+			yaml: "providers:\n  - name: fixer\n    key: abc\n    pairs: [{ from: EUR, to: USD }, { from: EUR, to: GBP }]\n" +
+				"derived:\n  - { symbol: SAP_USD, expr: SAP.DE * EUR }\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:6:30: derived SAP_USD: EUR is the base of EUR/USD and EUR/GBP, write the pair in quotes, e.g. "EUR/USD"`,
+		},
+		"invalid cron": {
+			yaml: "schedules:\n  - name: close\n    cron: \"30 25 * * *\"\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:3:11: schedule close: cron "30 25 * * *": hour: value 25 out of range 0-23`,
+		},
+		"invalid timezone": {
+			yaml: "schedules:\n  - name: close\n    cron: \"30 17 * * 1-5\"\n    timezone: Mars/Olympus\nledger: { price_db: /tmp/prices.db }\n",
+			want: ":4:15: schedule close: unknown time zone Mars/Olympus",
+		},
+		"unknown exchange": {
+			yaml: "providers:\n  - name: funds\n    stocks: [{ symbol: FUND1, exchange: XXXX }]\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:3:41: provider funds: unknown exchange "XXXX"`,
+		},
+		"unknown staleness policy": {
+			yaml: "staleness:\n  policy: drop\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:2:11: staleness.policy: unknown policy "drop", expected warn, skip or tag`,
+		},
+		"unknown stock currency": {
+			yaml: "providers:\n  - name: stooq\n    stocks: [{ symbol: VOD.L, currency: GBPX }]\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:3:41: provider stooq: currency: "GBPX" is neither an ISO 4217 code nor a minor unit such as GBX`,
+		},
+		"unknown reporting currency": {
+			yaml: "ledger:\n  price_db: /tmp/prices.db\n  currency: EURO\n",
+			want: `:3:13: ledger.currency: "EURO" is not an ISO 4217 currency code`,
+		},
+		"unknown ledger timezone": {
+			yaml: "ledger:\n  price_db: /tmp/prices.db\n  timezone: Europe/Atlantis\n",
+			want: `:3:13: ledger.timezone: unknown time zone Europe/Atlantis`,
+		},
+		"unknown dates policy": {
+			yaml: "ledger:\n  price_db: /tmp/prices.db\n  dates: daily\n",
+			want: `:3:10: ledger.dates: unknown policy "daily", expected timestamp, date or auto`,
+		},
+		"negative cache ttl": {
+			yaml: "cache:\n  ttl: -1h\nledger: { price_db: /tmp/prices.db }\n",
+			want: ":2:8: cache.ttl: must not be negative",
+		},
+		"unknown outlier action": {
+			yaml: "outliers:\n  max_change: 0.5\n  action: drop\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:3:11: outliers.action: unknown action "drop", expected quarantine or reject`,
+		},
+		"negative max_change": {
+			yaml: "outliers: { max_change: -1 }\nledger: { price_db: /tmp/prices.db }\n",
+			want: ":1:25: outliers.max_change: must not be negative",
+		},
+		"legacy section field": {
+			yaml: "fixer:\n  key: abc\n  key_file: secret\n  pairs: [{ from: EUR, to: USD }]\nledger: { price_db: /tmp/prices.db }\n",
+			want: `:3:3: unknown field "key_file", expected one of key, pairs`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfig(t, tt.yaml)
+			_, err := LoadConfig(path)
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !strings.Contains(err.Error(), path+tt.want) {
+				t.Errorf("expected error containing %q, got %q", path+tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	// A price DB that cannot be written is only an error for commands that
+	// write to it.
+	path := writeConfig(t, "ledger:\n  price_db: /non/existent/prices.db\n")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	err = cfg.Ledger.CheckWritable()
+	if want := path + ":2:13: ledger.price_db: cannot create /non/existent/prices.db"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected an error containing %q, got %v", want, err)
+	}
+
+	cfg, err = LoadConfig(writeConfig(t, "ledger:\n  price_db: "+filepath.Join(t.TempDir(), "prices.db")+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Ledger.CheckWritable(); err != nil {
+		t.Errorf("unexpected error for a writable price DB: %v", err)
+	}
+}
+
+func TestLoadConfig_ReportsAllErrors(t *testing.T) {
+	t.Setenv("LEDGER_PRICE_DB", "")
+	t.Setenv("LEDGER_INIT_FILE", "/non/existent/ledgerrc")
+	path := writeConfig(t, `
+providers:
+  - name: fixer
+    key: abc
+    pairs: [{ from: eur, to: USD }, { from: GBP, to: XXX }]
+`)
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	var cerr *Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected a *Error, got %T", err)
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 3 {
+		t.Errorf("expected 3 errors, got %q", lines)
+	}
+}
+
+func TestDecodeOptions_UnknownField(t *testing.T) {
+	path := writeConfig(t, `
+providers:
+  - name: funds
+    type: exec
+    options:
+      command: [nav]
+      curency: EUR
+ledger: { price_db: /tmp/prices.db }
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	var options struct {
+		Command  []string `yaml:"command"`
+		Currency string   `yaml:"currency"`
+	}
+	err = cfg.Providers[0].DecodeOptions(&options)
+	want := path + `:7:7: unknown field "curency", did you mean "currency"?`
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+	if pos := cfg.Providers[0].Position(); pos.Line != 3 || pos.Column != 5 {
+		t.Errorf("unexpected provider position %v", pos)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"stocks", "stocks", 0},
+		{"stoks", "stocks", 1},
+		{"marketstak", "marketstack", 1},
+		{"pairs", "stocks", 5},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
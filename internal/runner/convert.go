@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+// DefaultCurrency is the currency of stock prices whose currency is not
+// known, the one calais always wrote them in.
+const DefaultCurrency = "EUR"
+
+// Rate is the price of one unit of From in To.
+type Rate struct {
+	From, To string
+	Rate     float64
+}
+
+// rates are the exchange rates known to a run by pair. Rates fetched during
+// the run replace those given with WithCurrency.
+type rates map[[2]string]float64
+
+func (rs rates) add(from, to string, rate float64) {
+	if rate > 0 && from != to {
+		rs[[2]string{from, to}] = rate
+	}
+}
+
+// rate returns the price of one from in to, using the rate of the pair, its
+// inverse or a cross rate through a third currency.
+func (rs rates) rate(from, to string) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	if r, ok := rs.direct(from, to); ok {
+		return r, true
+	}
+	// Pairs are tried in order so that the same cross rate is chosen every
+	// time.
+	pairs := make([][2]string, 0, len(rs))
+	for pair := range rs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0]+pairs[i][1] < pairs[j][0]+pairs[j][1]
+	})
+	for _, pair := range pairs {
+		r := rs[pair]
+		var via string
+		switch {
+		case pair[0] == from:
+			via = pair[1]
+		case pair[1] == from:
+			via, r = pair[0], 1/r
+		default:
+			continue
+		}
+		if r2, ok := rs.direct(via, to); ok {
+			return r * r2, true
+		}
+	}
+	return 0, false
+}
+
+func (rs rates) direct(from, to string) (float64, bool) {
+	if r, ok := rs[[2]string{from, to}]; ok {
+		return r, true
+	}
+	if r, ok := rs[[2]string{to, from}]; ok {
+		return 1 / r, true
+	}
+	return 0, false
+}
+
+// convert returns the price in the reporting currency when the price has a
+// known currency and a rate to the reporting currency is known.
+func (r *Runner) convert(price float64, currency string) (float64, string, bool) {
+	if r.currency == "" || currency == "" || currency == r.currency {
+		return price, currency, true
+	}
+	rate, ok := r.rates.rate(currency, r.currency)
+	if !ok {
+		return price, currency, false
+	}
+	return price * rate, r.currency, true
+}
+
+// convertRecord converts the price of record into the reporting currency.
+// Without a rate the price is left in its own currency, and the reason is
+// returned for the report.
+func (r *Runner) convertRecord(record *doctype.Record) string {
+	price, currency, ok := r.convert(record.Price, record.Currency)
+	if !ok {
+		r.logger.Warn("no rate to convert price, writing it unconverted", "symbol", record.Symbol, "from", record.Currency, "to", r.currency)
+		return fmt.Sprintf("no rate from %s to %s", record.Currency, r.currency)
+	}
+	record.Price, record.Currency = price, currency
+	return ""
+}
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"strings"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+)
+
+// DerivedProvider is the provider name that selects derived prices in a
+// Filter.
+const DerivedProvider = "derived"
+
+// Filter narrows a run down to some of the configured entries. Empty fields
+// do not filter. When Symbols or Pairs is set, only the listed stocks, pairs
+// and derived symbols are kept. Providers and Tags further restrict what is
+// kept to the named provider instances and to entries carrying one of the
+// tags.
+type Filter struct {
+	Symbols   []string
+	Pairs     []string // FROM/TO
+	Providers []string
+	Tags      []string
+}
+
+// IsZero reports whether the filter keeps everything.
+func (f Filter) IsZero() bool {
+	return len(f.Symbols) == 0 && len(f.Pairs) == 0 && len(f.Providers) == 0 && len(f.Tags) == 0
+}
+
+// Sources returns the sources narrowed to the selected stocks and pairs.
+// Sources left with nothing to fetch are dropped.
+func (f Filter) Sources(sources []Source) []Source {
+	var out []Source
+	for _, s := range sources {
+		if len(f.Providers) > 0 && !contains(f.Providers, s.Config.Name) {
+			continue
+		}
+		var stocks []config.Stock
+		for _, st := range s.Config.Stocks {
+			if f.keepSymbol(st.Symbol) && f.keepTags(st.Tags) {
+				stocks = append(stocks, st)
+			}
+		}
+		var pairs []config.Pair
+		for _, p := range s.Config.Pairs {
+			if f.keepPair(p) && f.keepTags(p.Tags) {
+				pairs = append(pairs, p)
+			}
+		}
+		if len(stocks) == 0 && len(pairs) == 0 {
+			continue
+		}
+		s.Config.Stocks, s.Config.Pairs = stocks, pairs
+		out = append(out, s)
+	}
+	return out
+}
+
+// Derived returns the selected derived prices.
+func (f Filter) Derived(derived []config.DerivedConfig) []config.DerivedConfig {
+	if len(f.Providers) > 0 && !contains(f.Providers, DerivedProvider) {
+		return nil
+	}
+	var out []config.DerivedConfig
+	for _, d := range derived {
+		if (len(f.Symbols) > 0 || len(f.Pairs) > 0) && !containsFold(f.Symbols, d.Symbol) {
+			continue
+		}
+		if f.keepTags(d.Tags) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (f Filter) keepSymbol(symbol string) bool {
+	if len(f.Symbols) == 0 && len(f.Pairs) == 0 {
+		return true
+	}
+	return containsFold(f.Symbols, symbol)
+}
+
+func (f Filter) keepPair(p config.Pair) bool {
+	if len(f.Symbols) == 0 && len(f.Pairs) == 0 {
+		return true
+	}
+	return containsFold(f.Pairs, p.String())
+}
+
+func (f Filter) keepTags(tags []string) bool {
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		if contains(f.Tags, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+)
+
+func TestFilter(t *testing.T) {
+	sources := []Source{
+		{
+			Config: config.ProviderConfig{Name: "marketstack", Stocks: []config.Stock{
+				{Symbol: "AAPL", Tags: []string{"us"}},
+				{Symbol: "SXR8.DE", Tags: []string{"eu", "etf"}},
+			}},
+			Provider: &mockStocks{},
+		},
+		{
+			Config: config.ProviderConfig{Name: "fixer", Pairs: []config.Pair{
+				{From: "EUR", To: "USD"},
+				{From: "XAU", To: "USD", Tags: []string{"metals"}},
+			}},
+			Provider: mockCurrencies{},
+		},
+	}
+	derived := []config.DerivedConfig{
+		{Symbol: "GOLD_G", Expr: "XAU / 31.1035", Tags: []string{"metals"}},
+		{Symbol: "OTHER", Expr: "EUR * 2"},
+	}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		stocks  []string
+		pairs   []string
+		derived []string
+	}{
+		{
+			name:    "no filter",
+			stocks:  []string{"AAPL", "SXR8.DE"},
+			pairs:   []string{"EUR/USD", "XAU/USD"},
+			derived: []string{"GOLD_G", "OTHER"},
+		},
+		{
+			name:   "symbol",
+			filter: Filter{Symbols: []string{"aapl"}},
+			stocks: []string{"AAPL"},
+		},
+		{
+			name:   "pair",
+			filter: Filter{Pairs: []string{"eur/usd"}},
+			pairs:  []string{"EUR/USD"},
+		},
+		{
+			name:    "symbol, pair and derived symbol",
+			filter:  Filter{Symbols: []string{"SXR8.DE", "GOLD_G"}, Pairs: []string{"XAU/USD"}},
+			stocks:  []string{"SXR8.DE"},
+			pairs:   []string{"XAU/USD"},
+			derived: []string{"GOLD_G"},
+		},
+		{
+			name:   "provider",
+			filter: Filter{Providers: []string{"fixer"}},
+			pairs:  []string{"EUR/USD", "XAU/USD"},
+		},
+		{
+			name:    "derived provider",
+			filter:  Filter{Providers: []string{"derived"}},
+			derived: []string{"GOLD_G", "OTHER"},
+		},
+		{
+			name:    "tag",
+			filter:  Filter{Tags: []string{"metals", "etf"}},
+			stocks:  []string{"SXR8.DE"},
+			pairs:   []string{"XAU/USD"},
+			derived: []string{"GOLD_G"},
+		},
+		{
+			name:   "provider and tag",
+			filter: Filter{Providers: []string{"marketstack"}, Tags: []string{"us"}},
+			stocks: []string{"AAPL"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stocks, pairs, names []string
+			for _, s := range tt.filter.Sources(sources) {
+				for _, stock := range s.Stocks() {
+					stocks = append(stocks, stock.Symbol)
+				}
+				for _, p := range s.Pairs() {
+					pairs = append(pairs, p.String())
+				}
+			}
+			for _, d := range tt.filter.Derived(derived) {
+				names = append(names, d.Symbol)
+			}
+			if !reflect.DeepEqual(stocks, tt.stocks) || !reflect.DeepEqual(pairs, tt.pairs) || !reflect.DeepEqual(names, tt.derived) {
+				t.Errorf("got stocks %v, pairs %v, derived %v; want %v, %v, %v",
+					stocks, pairs, names, tt.stocks, tt.pairs, tt.derived)
+			}
+		})
+	}
+
+	if len(sources[0].Config.Stocks) != 2 {
+		t.Error("filter modified the original sources")
+	}
+	if !(Filter{}).IsZero() || (Filter{Tags: []string{"x"}}).IsZero() {
+		t.Error("unexpected IsZero result")
+	}
+}
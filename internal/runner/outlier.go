@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"fmt"
+	"math"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+// invalid returns why price cannot be a price at all, or "" if it can.
+func invalid(price float64) string {
+	switch {
+	case math.IsNaN(price) || math.IsInf(price, 0):
+		return fmt.Sprintf("invalid price %v", price)
+	case price <= 0:
+		return fmt.Sprintf("price %v is not positive", price)
+	}
+	return ""
+}
+
+// QuoteKey identifies the price of symbol in currency, e.g. EUR/USD, so that
+// prices of one symbol in different quotes are told apart.
+func QuoteKey(symbol, currency string) string {
+	return symbol + "/" + currency
+}
+
+// outlier returns how the price of record differs from the latest price of
+// its symbol in the same quote when that is by more than the allowed change,
+// or "".
+func (r *Runner) outlier(record doctype.Record) string {
+	last, ok := r.last[QuoteKey(record.Symbol, record.Currency)]
+	if r.maxChange == 0 || !ok || last <= 0 {
+		return ""
+	}
+	if change := record.Price/last - 1; math.Abs(change) > r.maxChange {
+		return fmt.Sprintf("%+.0f%% from %g", change*100, last)
+	}
+	return ""
+}
+
+// checkOutlier rejects a record that is not a valid price and quarantines
+// or rejects an outlier. It reports whether the record should be written.
+func (r *Runner) checkOutlier(record doctype.Record, symbol, provider, kind string) bool {
+	if reason := invalid(record.Price); reason != "" {
+		r.logger.Error("rejected price", "symbol", symbol, "reason", reason)
+		r.report.fail(symbol, provider, kind, fmt.Errorf("rejected: %s", reason))
+		return false
+	}
+	reason := r.outlier(record)
+	if reason == "" {
+		return true
+	}
+	if r.quarantine == nil {
+		r.logger.Error("rejected outlier", "symbol", symbol, "price", record.Price, "change", reason)
+		r.report.fail(symbol, provider, kind, fmt.Errorf("rejected outlier: %s", reason))
+		return false
+	}
+	record.Note = "outlier: " + reason
+	if err := r.quarantine.Append(record); err != nil {
+		r.logger.Error("failed to quarantine price", "symbol", symbol, "error", err)
+		r.report.fail(symbol, provider, kind, err)
+		return false
+	}
+	r.logger.Info("quarantined outlier", "symbol", symbol, "price", record.Price, "change", reason)
+	r.report.add(symbol, provider, kind, record.Price, record.Time, StatusQuarantined).Outlier = reason
+	return false
+}
@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+// Status is the outcome of fetching and writing one price.
+type Status string
+
+const (
+	StatusOK     Status = "ok"
+	StatusFailed Status = "failed"
+	// StatusSkipped is a stale price left out by the skip policy.
+	StatusSkipped Status = "skipped"
+	// StatusQuarantined is an outlier written to the quarantine file.
+	StatusQuarantined Status = "quarantined"
+)
+
+// Result is the outcome for one symbol of a run.
+type Result struct {
+	Symbol   string     `json:"symbol"`
+	Provider string     `json:"provider"`
+	Kind     string     `json:"kind"` // stock, currency or derived
+	Price    float64    `json:"price,omitempty"`
+	Date     *time.Time `json:"date,omitempty"`
+	Status   Status     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	// Stale tells why the price is stale, if it is.
+	Stale string `json:"stale,omitempty"`
+	// Outlier tells how a quarantined price differs from the last one.
+	Outlier string `json:"outlier,omitempty"`
+	// Unconverted tells why a price was written in its own currency rather
+	// than the reporting currency.
+	Unconverted string `json:"unconverted,omitempty"`
+}
+
+// Report is the machine-readable summary of a run.
+type Report struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Results  []Result  `json:"results"`
+}
+
+// Failed returns the number of results that did not succeed.
+func (r *Report) Failed() int {
+	var n int
+	for _, res := range r.Results {
+		if res.Status == StatusFailed {
+			n++
+		}
+	}
+	return n
+}
+
+// Stale returns the symbols whose price was stale, whether written or not.
+func (r *Report) Stale() []string {
+	return r.symbols(func(res Result) bool { return res.Stale != "" })
+}
+
+// Unconverted returns the symbols whose price was written in its own
+// currency for want of a rate.
+func (r *Report) Unconverted() []string {
+	return r.symbols(func(res Result) bool { return res.Unconverted != "" })
+}
+
+// Quarantined returns the symbols whose price awaits approval.
+func (r *Report) Quarantined() []string {
+	return r.symbols(func(res Result) bool { return res.Status == StatusQuarantined })
+}
+
+func (r *Report) symbols(match func(Result) bool) []string {
+	var symbols []string
+	for _, res := range r.Results {
+		if match(res) {
+			symbols = append(symbols, res.Symbol)
+		}
+	}
+	return symbols
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// AddBuildErrors records a failed result for every stock and pair of the
+// provider instances that could not be built, as returned by Build.
+func (r *Report) AddBuildErrors(errs []error) {
+	for _, err := range errs {
+		var be *BuildError
+		if !errors.As(err, &be) {
+			r.Results = append(r.Results, Result{Status: StatusFailed, Error: err.Error()})
+			continue
+		}
+		pc := be.Config
+		if len(pc.Stocks) == 0 && len(pc.Pairs) == 0 {
+			r.Results = append(r.Results, Result{Provider: pc.Name, Status: StatusFailed, Error: be.Err.Error()})
+		}
+		for _, s := range pc.Stocks {
+			r.fail(s.Symbol, pc.Name, "stock", be.Err)
+		}
+		for _, p := range pc.Pairs {
+			r.fail(p.String(), pc.Name, "currency", be.Err)
+		}
+	}
+}
+
+func (r *Report) ok(symbol, provider, kind string, price float64, date time.Time) *Result {
+	return r.add(symbol, provider, kind, price, date, StatusOK)
+}
+
+// add records a price that was fetched, whether it was written or not.
+func (r *Report) add(symbol, provider, kind string, price float64, date time.Time, status Status) *Result {
+	r.Results = append(r.Results, Result{
+		Symbol:   symbol,
+		Provider: provider,
+		Kind:     kind,
+		Price:    price,
+		Date:     &date,
+		Status:   status,
+	})
+	return &r.Results[len(r.Results)-1]
+}
+
+func (r *Report) fail(symbol, provider, kind string, err error) {
+	r.Results = append(r.Results, Result{
+		Symbol:   symbol,
+		Provider: provider,
+		Kind:     kind,
+		Status:   StatusFailed,
+		Error:    err.Error(),
+	})
+}
+
+// BuildError reports a provider instance that could not be created. Its
+// message cites the position of the instance in the configuration, or of the
+// option at fault, unless the error already cites a more precise one.
+type BuildError struct {
+	Config config.ProviderConfig
+	Err    error
+}
+
+func (e *BuildError) Error() string {
+	var (
+		cerr *config.Error
+		oerr *providers.OptionError
+	)
+	if errors.As(e.Err, &oerr) {
+		if pos := e.Config.OptionPosition(oerr.Path...); pos.File != "" {
+			return pos.String() + ": " + e.Err.Error()
+		}
+	}
+	if pos := e.Config.Position(); pos.File != "" && !errors.As(e.Err, &cerr) {
+		return pos.String() + ": " + e.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+func (e *BuildError) Unwrap() error { return e.Err }
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+)
+
+func TestReport_AddBuildErrors(t *testing.T) {
+	r := &Report{}
+	r.AddBuildErrors([]error{
+		&BuildError{
+			Config: config.ProviderConfig{
+				Name:   "ms",
+				Stocks: []config.Stock{{Symbol: "AAPL"}, {Symbol: "MSFT"}},
+				Pairs:  []config.Pair{{From: "EUR", To: "USD"}},
+			},
+			Err: errors.New("missing API key"),
+		},
+		&BuildError{Config: config.ProviderConfig{Name: "empty"}, Err: errors.New("bad options")},
+		errors.New("other"),
+	})
+
+	if len(r.Results) != 5 || r.Failed() != 5 {
+		t.Fatalf("unexpected results: %+v", r.Results)
+	}
+	if res := r.Results[2]; res.Symbol != "EUR/USD" || res.Kind != "currency" || res.Error != "missing API key" {
+		t.Errorf("unexpected pair result: %+v", res)
+	}
+	if res := r.Results[3]; res.Provider != "empty" || res.Symbol != "" {
+		t.Errorf("unexpected result for instance without entries: %+v", res)
+	}
+}
+
+func TestReport_WriteJSON(t *testing.T) {
+	r := &Report{}
+	r.ok("AAPL", "ms", "stock", 150.75, time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC))
+	r.fail("MSFT", "ms", "stock", errors.New("no data"))
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var decoded struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if len(decoded.Results) != 2 {
+		t.Fatalf("unexpected results: %v", decoded.Results)
+	}
+	ok, failed := decoded.Results[0], decoded.Results[1]
+	if ok["status"] != "ok" || ok["price"] != 150.75 || ok["date"] != "2025-08-18T00:00:00Z" || ok["error"] != nil {
+		t.Errorf("unexpected ok result: %v", ok)
+	}
+	if failed["status"] != "failed" || failed["error"] != "no data" || failed["date"] != nil || failed["price"] != nil {
+		t.Errorf("unexpected failed result: %v", failed)
+	}
+}
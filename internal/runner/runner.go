@@ -0,0 +1,446 @@
+// Package runner implements the fetch and write pipeline shared by the
+// calais commands: it builds the configured provider instances, fetches
+// their stocks and currency pairs, evaluates derived prices and hands every
+// record to a doctype.PriceWriter.
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/expr"
+	"git.sr.ht/~atmosx/calais/pkg/log"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+// Source is a provider instance built from configuration.
+type Source struct {
+	Config   config.ProviderConfig
+	Provider interface{}
+}
+
+// Stocks returns the stocks the source fetches.
+func (s Source) Stocks() []config.Stock {
+	if _, ok := s.Provider.(providers.StockProvider); !ok {
+		return nil
+	}
+	return s.Config.Stocks
+}
+
+// Pairs returns the currency pairs the source fetches.
+func (s Source) Pairs() []config.Pair {
+	if _, ok := s.Provider.(providers.CurrencyProvider); !ok {
+		return nil
+	}
+	return s.Config.Pairs
+}
+
+// Build creates the provider instances listed in cfg. Instances that fail to
+// build are left out and their errors returned as *BuildError values.
+// Providers that know their own symbols are asked for them when the
+// configuration lists none.
+func Build(cfg *config.Config, client providers.HTTPDoer, logger *log.Logger) ([]Source, []error) {
+	var (
+		sources []Source
+		errs    []error
+	)
+	for _, pc := range cfg.Providers {
+		p, err := providers.New(pc.ProviderType(), providers.Instance{
+			Name:   pc.Name,
+			Key:    pc.Key,
+			Client: client,
+			Logger: logger,
+		}, pc.DecodeOptions)
+		if err != nil {
+			errs = append(errs, &BuildError{Config: pc, Err: err})
+			continue
+		}
+		if lister, ok := p.(providers.SymbolLister); ok && len(pc.Stocks) == 0 {
+			for _, symbol := range lister.Symbols() {
+				pc.Stocks = append(pc.Stocks, config.Stock{Symbol: symbol})
+			}
+		}
+		sources = append(sources, Source{Config: pc, Provider: p})
+	}
+	return sources, errs
+}
+
+type Runner struct {
+	sources   []Source
+	derived   []config.DerivedConfig
+	writer    doctype.PriceWriter
+	logger    *log.Logger
+	staleness config.StalenessConfig
+	now       func() time.Time
+
+	// maxChange, last and quarantine configure the outlier checks, see
+	// WithOutliers.
+	maxChange  float64
+	last       map[string]float64
+	quarantine doctype.PriceWriter
+
+	// currency is the reporting currency stock prices are converted into
+	// with rates.
+	currency string
+	rates    rates
+
+	// prices holds the records written during a run by symbol so that
+	// derived prices can refer to them.
+	prices map[string]doctype.Record
+	report *Report
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithStaleness checks the fetched prices for staleness as configured by
+// cfg, see config.StalenessConfig.
+func WithStaleness(cfg config.StalenessConfig) Option {
+	return func(r *Runner) { r.staleness = cfg }
+}
+
+// WithOutliers checks every fetched price against last, the latest price of
+// each symbol in the price DB keyed by symbol and quote, e.g. AAPL/USD.
+// Prices that moved by more than maxChange, a fraction, are written to
+// quarantine instead, or dropped if it is nil. Backfill checks each price
+// against the one before it instead.
+func WithOutliers(maxChange float64, last map[string]float64, quarantine doctype.PriceWriter) Option {
+	return func(r *Runner) {
+		r.maxChange, r.last, r.quarantine = maxChange, last, quarantine
+	}
+}
+
+// WithCurrency converts stock prices into the reporting currency code.
+// Currency pairs are fetched first so that their rates can be used, and
+// known holds the rates to use for currencies the run does not fetch, such
+// as the latest ones in the price DB.
+func WithCurrency(code string, known []Rate) Option {
+	return func(r *Runner) {
+		r.currency = code
+		for _, rate := range known {
+			r.rates.add(rate.From, rate.To, rate.Rate)
+		}
+	}
+}
+
+func New(sources []Source, derived []config.DerivedConfig, writer doctype.PriceWriter, logger *log.Logger, opts ...Option) *Runner {
+	r := &Runner{
+		sources: sources,
+		derived: derived,
+		writer:  writer,
+		logger:  logger,
+		now:     time.Now,
+		rates:   rates{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run fetches the latest price of every configured stock and currency pair,
+// then writes the derived prices. The report lists the outcome per symbol.
+func (r *Runner) Run() *Report {
+	r.start()
+	if r.currency != "" {
+		for _, s := range r.sources {
+			if cp, ok := s.Provider.(providers.CurrencyProvider); ok {
+				r.fetchCurrencies(s.Config.Name, cp, s.Pairs())
+			}
+		}
+	}
+	for _, s := range r.sources {
+		if sp, ok := s.Provider.(providers.StockProvider); ok {
+			r.fetchStocks(s.Config.Name, sp, s.Stocks())
+		}
+		if cp, ok := s.Provider.(providers.CurrencyProvider); ok && r.currency == "" {
+			r.fetchCurrencies(s.Config.Name, cp, s.Pairs())
+		}
+	}
+	r.writeDerived()
+	return r.finish()
+}
+
+func (r *Runner) start() {
+	r.prices = map[string]doctype.Record{}
+	r.report = &Report{Started: r.now()}
+}
+
+func (r *Runner) finish() *Report {
+	r.report.Finished = r.now()
+	if stale := r.report.Stale(); len(stale) > 0 {
+		r.logger.Info("stale prices", "symbols", strings.Join(stale, ","), "policy", r.staleness.Policy)
+	}
+	if quarantined := r.report.Quarantined(); len(quarantined) > 0 {
+		r.logger.Info("quarantined outliers, accept them with calais approve", "symbols", strings.Join(quarantined, ","))
+	}
+	return r.report
+}
+
+// Backfill writes the end-of-day prices between from and to of every stock
+// whose provider supports historical ranges. Other sources are skipped. The
+// report holds the latest price written per symbol.
+func (r *Runner) Backfill(from, to time.Time) *Report {
+	r.start()
+	// The latest prices in the price DB say nothing about older ones.
+	r.last = map[string]float64{}
+	for _, s := range r.sources {
+		hp, ok := s.Provider.(providers.HistoricalStockProvider)
+		if !ok {
+			r.logger.Info("provider does not support backfill", "provider", s.Config.Name)
+			continue
+		}
+		for _, stock := range s.Stocks() {
+			symbol := stock.Symbol
+			data, err := hp.FetchStockRange(symbol, from, to)
+			if err != nil {
+				r.logger.Error("failed to fetch stock history", "symbol", symbol, "error", err)
+				r.report.fail(symbol, s.Config.Name, "stock", err)
+				continue
+			}
+			last, n, err := r.appendAll(s.Config.Name, stock, data)
+			if err != nil {
+				r.logger.Error("failed to write stock price", "symbol", symbol, "error", err)
+				r.report.fail(symbol, s.Config.Name, "stock", err)
+				continue
+			}
+			if n > 0 {
+				r.report.ok(symbol, s.Config.Name, "stock", last.Price, last.Time)
+			}
+			r.logger.Info("wrote stock history", "symbol", symbol, "prices", n)
+		}
+	}
+	return r.finish()
+}
+
+// appendAll writes the prices in data that pass the outlier checks, each
+// judged against the one written before it, and returns the last one written
+// and how many were. Historical prices are not converted: the rates of their
+// days are not known.
+func (r *Runner) appendAll(provider string, stock config.Stock, data []providers.StockData) (doctype.Record, int, error) {
+	var (
+		last doctype.Record
+		n    int
+	)
+	for _, sd := range data {
+		record, err := r.stockRecord(&sd, stock, provider)
+		if err != nil {
+			return last, n, err
+		}
+		if r.currency != "" && record.Currency != r.currency {
+			return last, n, fmt.Errorf("cannot convert historical prices from %s into %s", record.Currency, r.currency)
+		}
+		if !r.checkOutlier(record, stock.Symbol, provider, "stock") {
+			continue
+		}
+		if err := r.writer.Append(record); err != nil {
+			return last, n, err
+		}
+		r.last[QuoteKey(record.Symbol, record.Currency)] = record.Price
+		last = record
+		n++
+	}
+	return last, n, nil
+}
+
+// stockRecord returns the record of sd fetched by provider, converted into
+// the major currency unit when it is quoted in one such as GBX. The currency
+// reported by the provider takes precedence over the one configured for the
+// stock, and that over the currency of its exchange. A minor unit is never
+// taken from the exchange, as London also lists shares in dollars, pounds and
+// euros, which would be written a hundredth of their price. A price in none of
+// them is in DefaultCurrency, unless prices are converted into a reporting
+// currency: that would take a guess for a fact.
+func (r *Runner) stockRecord(sd *providers.StockData, stock config.Stock, provider string) (doctype.Record, error) {
+	currency := sd.Currency
+	if currency == "" {
+		currency = stock.Currency
+	}
+	if ex, ok := stock.Calendar(); ok && currency == "" && !providers.IsMinorUnit(ex.Currency) {
+		currency = ex.Currency
+	}
+	switch {
+	case currency != "":
+	case r.currency != "":
+		return doctype.Record{}, fmt.Errorf("currency of %s not known, set the currency of the stock", stock.Symbol)
+	default:
+		currency = DefaultCurrency
+	}
+	price, currency := providers.MajorUnit(sd.Close, currency)
+	return doctype.Record{
+		Time:     sd.Date,
+		Symbol:   sd.Symbol,
+		Price:    price,
+		Kind:     "commodity",
+		Currency: currency,
+		Provider: provider,
+	}, nil
+}
+
+func (r *Runner) fetchStocks(name string, provider providers.StockProvider, stocks []config.Stock) {
+	fp, fixed := provider.(providers.FixedPricer)
+	fixed = fixed && fp.FixedPrices()
+	for _, stock := range stocks {
+		symbol := stock.Symbol
+		sd, err := provider.FetchStock(symbol)
+		if err != nil {
+			r.logger.Error("failed to fetch stock", "symbol", symbol, "error", err)
+			r.report.fail(symbol, name, "stock", err)
+			continue
+		}
+		record, err := r.stockRecord(sd, stock, name)
+		if err != nil {
+			r.logger.Error("failed to fetch stock", "symbol", symbol, "error", err)
+			r.report.fail(symbol, name, "stock", err)
+			continue
+		}
+		unconverted := r.convertRecord(&record)
+		if !r.checkOutlier(record, symbol, name, "stock") {
+			continue
+		}
+		var stale string
+		if !fixed {
+			stale = r.staleStock(stock, record.Time)
+		}
+		if !r.applyStaleness(&record, symbol, name, "stock", stale) {
+			continue
+		}
+		if err := r.writer.Append(record); err != nil {
+			r.logger.Error("failed to write stock price", "symbol", symbol, "error", err)
+			r.report.fail(symbol, name, "stock", err)
+			continue
+		}
+		r.prices[record.Symbol] = record
+		res := r.report.ok(symbol, name, "stock", record.Price, record.Time)
+		res.Stale, res.Unconverted = stale, unconverted
+		r.logger.Info("wrote stock price", "symbol", sd.Symbol, "price", record.Price, "currency", record.Currency, "date", sd.Date)
+	}
+}
+
+func (r *Runner) fetchCurrencies(name string, provider providers.CurrencyProvider, pairs []config.Pair) {
+	for _, p := range pairs {
+		cd, err := provider.FetchCurrency(p.From, p.To)
+		if err != nil {
+			r.logger.Error("failed to fetch currency", "pair", p.String(), "error", err)
+			r.report.fail(p.String(), name, "currency", err)
+			continue
+		}
+		record := doctype.Record{
+			Time:     cd.Date,
+			Symbol:   cd.From,
+			Price:    cd.Rate,
+			Kind:     "currency",
+			Currency: cd.To,
+			Provider: name,
+		}
+		if !r.checkOutlier(record, p.String(), name, "currency") {
+			continue
+		}
+		stale := r.staleAge(cd.Date, p.MaxAge)
+		if !r.applyStaleness(&record, p.String(), name, "currency", stale) {
+			continue
+		}
+		if err := r.writer.Append(record); err != nil {
+			r.logger.Error("failed to write currency price", "pair", p.String(), "error", err)
+			r.report.fail(p.String(), name, "currency", err)
+			continue
+		}
+		// Expressions name a rate by its pair, or by its base currency when
+		// no other pair has the same one, as checked with the configuration.
+		r.prices[p.String()] = record
+		r.prices[record.Symbol] = record
+		r.rates.add(cd.From, cd.To, cd.Rate)
+		r.report.ok(p.String(), name, "currency", record.Price, record.Time).Stale = stale
+		r.logger.Info("wrote currency price", "pair", p.String(), "rate", cd.Rate, "date", cd.Date)
+	}
+}
+
+// writeDerived evaluates each derived price against the prices fetched so far,
+// in configuration order, so later entries may build on earlier ones. The
+// derived price is dated like the most recent of its inputs, or at the time of
+// the run when it has none.
+func (r *Runner) writeDerived() {
+	for _, d := range r.derived {
+		e, err := expr.Parse(d.Expr)
+		if err != nil {
+			r.logger.Error("invalid derived price", "symbol", d.Symbol, "error", err)
+			r.report.fail(d.Symbol, DerivedProvider, "derived", err)
+			continue
+		}
+		vars := make(map[string]float64, len(r.prices))
+		for symbol, rec := range r.prices {
+			vars[symbol] = rec.Price
+		}
+		price, err := e.Eval(vars)
+		if err != nil {
+			r.logger.Error("failed to derive price", "symbol", d.Symbol, "error", err)
+			r.report.fail(d.Symbol, DerivedProvider, "derived", err)
+			continue
+		}
+
+		var date time.Time
+		for _, v := range e.Vars() {
+			if t := r.prices[v].Time; t.After(date) {
+				date = t
+			}
+		}
+		if date.IsZero() {
+			date = r.now()
+		}
+		currency, err := r.derivedCurrency(d, e.Vars())
+		if err != nil {
+			r.logger.Error("failed to derive price", "symbol", d.Symbol, "error", err)
+			r.report.fail(d.Symbol, DerivedProvider, "derived", err)
+			continue
+		}
+		record := doctype.Record{
+			Time:     date,
+			Symbol:   d.Symbol,
+			Price:    price,
+			Kind:     "commodity",
+			Currency: currency,
+			Provider: DerivedProvider,
+		}
+		unconverted := r.convertRecord(&record)
+		if !r.checkOutlier(record, d.Symbol, DerivedProvider, "derived") {
+			continue
+		}
+		if err := r.writer.Append(record); err != nil {
+			r.logger.Error("failed to write derived price", "symbol", d.Symbol, "error", err)
+			r.report.fail(d.Symbol, DerivedProvider, "derived", err)
+			continue
+		}
+		r.prices[record.Symbol] = record
+		r.report.ok(d.Symbol, DerivedProvider, "derived", record.Price, date).Unconverted = unconverted
+		r.logger.Info("wrote derived price", "symbol", d.Symbol, "price", record.Price, "currency", record.Currency, "date", date)
+	}
+}
+
+// derivedCurrency returns the currency of d: the one configured, or else the
+// one its inputs vars share. Inputs in different currencies, such as a stock
+// in euros times a rate in dollars, need a configured currency.
+func (r *Runner) derivedCurrency(d config.DerivedConfig, vars []string) (string, error) {
+	if d.Currency != "" {
+		return d.Currency, nil
+	}
+	var currency string
+	for _, v := range vars {
+		switch c := r.prices[v].Currency; {
+		case currency == "":
+			currency = c
+		case c != currency:
+			return "", fmt.Errorf("inputs in %s and %s, set the currency of the derived price", currency, c)
+		}
+	}
+	if currency == "" && r.currency != "" {
+		return "", errors.New("currency not known, set the currency of the derived price")
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return currency, nil
+}
@@ -0,0 +1,601 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/log"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/all"
+	"git.sr.ht/~atmosx/calais/pkg/providers/manual"
+	"git.sr.ht/~atmosx/calais/pkg/providers/stooq"
+)
+
+var day = time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+
+type mockWriter struct {
+	records []doctype.Record
+}
+
+func (m *mockWriter) Append(r doctype.Record) error {
+	m.records = append(m.records, r)
+	return nil
+}
+
+type mockStocks struct {
+	prices     map[string]float64
+	currencies map[string]string
+}
+
+func (m *mockStocks) FetchStock(symbol string) (*providers.StockData, error) {
+	p, ok := m.prices[symbol]
+	if !ok {
+		return nil, errors.New("unknown symbol")
+	}
+	return &providers.StockData{Symbol: symbol, Date: day, Close: p, Currency: m.currencies[symbol]}, nil
+}
+
+func (m *mockStocks) FetchStockRange(symbol string, from, to time.Time) ([]providers.StockData, error) {
+	var data []providers.StockData
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		data = append(data, providers.StockData{Symbol: symbol, Date: d, Close: m.prices[symbol], Currency: m.currencies[symbol]})
+	}
+	return data, nil
+}
+
+type mockCurrencies struct{}
+
+func (mockCurrencies) FetchCurrency(from, to string) (*providers.CurrencyData, error) {
+	return &providers.CurrencyData{From: from, To: to, Rate: 3110.35, Date: day.Add(time.Hour)}, nil
+}
+
+// mockRates returns fixed rates by pair.
+type mockRates map[string]float64
+
+func (m mockRates) FetchCurrency(from, to string) (*providers.CurrencyData, error) {
+	rate, ok := m[from+"/"+to]
+	if !ok {
+		return nil, errors.New("unknown pair")
+	}
+	return &providers.CurrencyData{From: from, To: to, Rate: rate, Date: day}, nil
+}
+
+func testLogger() *log.Logger { return log.New(io.Discard, "Error") }
+
+func TestRun(t *testing.T) {
+	sources := []Source{
+		{
+			Config:   config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{{Symbol: "AAPL"}, {Symbol: "MISSING"}}},
+			Provider: &mockStocks{prices: map[string]float64{"AAPL": 150}},
+		},
+		{
+			Config:   config.ProviderConfig{Name: "fx", Pairs: []config.Pair{{From: "XAU", To: "USD"}}},
+			Provider: mockCurrencies{},
+		},
+	}
+	derived := []config.DerivedConfig{
+		{Symbol: "GOLD_G", Expr: "XAU / 31.1035"},
+		{Symbol: "GOLD_KG", Expr: "GOLD_G * 1000"},
+		{Symbol: "BROKEN", Expr: "NOPE * 2"},
+	}
+	w := &mockWriter{}
+	report := New(sources, derived, w, testLogger()).Run()
+
+	if len(w.records) != 4 {
+		t.Fatalf("expected 4 records, got %d: %+v", len(w.records), w.records)
+	}
+	if r := w.records[0]; r.Symbol != "AAPL" || r.Price != 150 || r.Kind != "commodity" || r.Currency != DefaultCurrency || r.Provider != "stocks" {
+		t.Errorf("unexpected stock record: %+v", r)
+	}
+	if r := w.records[1]; r.Symbol != "XAU" || r.Kind != "currency" || r.Provider != "fx" {
+		t.Errorf("unexpected currency record: %+v", r)
+	}
+	if r := w.records[2]; r.Symbol != "GOLD_G" || math.Abs(r.Price-100) > 1e-9 || r.Currency != "USD" || !r.Time.Equal(day.Add(time.Hour)) || r.Provider != DerivedProvider {
+		t.Errorf("unexpected derived record: %+v", r)
+	}
+	if r := w.records[3]; r.Symbol != "GOLD_KG" || math.Abs(r.Price-100000) > 1e-6 {
+		t.Errorf("unexpected derived record: %+v", r)
+	}
+
+	if len(report.Results) != 6 || report.Failed() != 2 {
+		t.Fatalf("unexpected report: %+v", report.Results)
+	}
+	if res := report.Results[1]; res.Symbol != "MISSING" || res.Provider != "stocks" || res.Status != StatusFailed || res.Error == "" {
+		t.Errorf("unexpected failed result: %+v", res)
+	}
+	if res := report.Results[2]; res.Symbol != "XAU/USD" || res.Kind != "currency" || res.Status != StatusOK || !res.Date.Equal(day.Add(time.Hour)) {
+		t.Errorf("unexpected currency result: %+v", res)
+	}
+	if res := report.Results[5]; res.Symbol != "BROKEN" || res.Provider != DerivedProvider || res.Status != StatusFailed {
+		t.Errorf("unexpected derived result: %+v", res)
+	}
+	if report.Finished.Before(report.Started) {
+		t.Errorf("unexpected report times: %v - %v", report.Started, report.Finished)
+	}
+}
+
+func TestRun_Staleness(t *testing.T) {
+	sources := []Source{
+		{
+			Config: config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{
+				{Symbol: "AAPL.US"},
+				{Symbol: "SAP.DE", MaxAge: 72 * time.Hour},
+				{Symbol: "7203.T"},
+			}},
+			Provider: &mockStocks{prices: map[string]float64{"AAPL.US": 150, "SAP.DE": 230, "7203.T": 2800}},
+		},
+		{
+			Config:   config.ProviderConfig{Name: "manual", Stocks: []config.Stock{{Symbol: "PENSION"}}},
+			Provider: manual.New([]manual.Price{{Symbol: "PENSION", Price: 12, Date: day.AddDate(0, -3, 0)}}),
+		},
+		{
+			Config:   config.ProviderConfig{Name: "fx", Pairs: []config.Pair{{From: "XAU", To: "USD"}}},
+			Provider: mockCurrencies{},
+		},
+	}
+	// Friday evening in New York: the prices of Thursday are a session old.
+	// SAP.DE is only checked against its own max_age, 7203.T, on an unknown
+	// exchange, against max_age, and the manual PENSION never.
+	now := time.Date(2025, 9, 19, 22, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		cfg     config.StalenessConfig
+		written string
+		stale   string
+		note    string
+	}{
+		{"no policy", config.StalenessConfig{MaxAge: time.Hour}, "AAPL.US SAP.DE 7203.T PENSION XAU", "", ""},
+		{"warn", config.StalenessConfig{Policy: "warn", MaxAge: 24 * time.Hour}, "AAPL.US SAP.DE 7203.T PENSION XAU", "AAPL.US 7203.T XAU/USD", ""},
+		{"skip", config.StalenessConfig{Policy: "skip"}, "SAP.DE 7203.T PENSION XAU", "AAPL.US", ""},
+		{"skip old", config.StalenessConfig{Policy: "skip", MaxAge: 24 * time.Hour}, "SAP.DE PENSION", "AAPL.US 7203.T XAU/USD", ""},
+		{"tag", config.StalenessConfig{Policy: "tag"}, "AAPL.US SAP.DE 7203.T PENSION XAU", "AAPL.US", "stale: expected the close of 2025-09-19"},
+		{"grace", config.StalenessConfig{Policy: "skip", Grace: 12 * time.Hour}, "AAPL.US SAP.DE 7203.T PENSION XAU", "", ""},
+	}
+	for _, tt := range tests {
+		w := &mockWriter{}
+		r := New(sources, nil, w, testLogger(), WithStaleness(tt.cfg))
+		r.now = func() time.Time { return now }
+		report := r.Run()
+
+		var written []string
+		for _, rec := range w.records {
+			written = append(written, rec.Symbol)
+		}
+		if got := strings.Join(written, " "); got != tt.written {
+			t.Errorf("%s: wrote %q, want %q", tt.name, got, tt.written)
+		}
+		if got := strings.Join(report.Stale(), " "); got != tt.stale {
+			t.Errorf("%s: stale %q, want %q", tt.name, got, tt.stale)
+		}
+		if tt.note != "" && w.records[0].Note != tt.note {
+			t.Errorf("%s: unexpected note %q", tt.name, w.records[0].Note)
+		}
+		if report.Failed() != 0 {
+			t.Errorf("%s: unexpected failures: %+v", tt.name, report.Results)
+		}
+		if tt.name == "skip" && report.Results[0].Status != StatusSkipped {
+			t.Errorf("%s: unexpected result %+v", tt.name, report.Results[0])
+		}
+	}
+}
+
+func TestRun_Outliers(t *testing.T) {
+	sources := []Source{
+		{
+			Config: config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{{Symbol: "AAPL"}, {Symbol: "VOD.L"}, {Symbol: "ZERO"}, {Symbol: "NEW"}}},
+			Provider: &mockStocks{
+				prices:     map[string]float64{"AAPL": 150, "VOD.L": 7200, "ZERO": 0, "NEW": 10},
+				currencies: map[string]string{"AAPL": "USD", "VOD.L": "GBP", "ZERO": "USD", "NEW": "USD"},
+			},
+		},
+	}
+	last := map[string]float64{"AAPL/USD": 140, "VOD.L/GBP": 72, "ZERO/USD": 5, "NEW/EUR": 1}
+
+	w, q := &mockWriter{}, &mockWriter{}
+	report := New(sources, nil, w, testLogger(), WithOutliers(0.5, last, q)).Run()
+	if len(w.records) != 2 || w.records[0].Symbol != "AAPL" || w.records[1].Symbol != "NEW" {
+		t.Errorf("unexpected records: %+v", w.records)
+	}
+	if len(q.records) != 1 || q.records[0].Symbol != "VOD.L" || q.records[0].Note != "outlier: +9900% from 72" {
+		t.Errorf("unexpected quarantined records: %+v", q.records)
+	}
+	if res := report.Results[1]; res.Status != StatusQuarantined || res.Outlier == "" {
+		t.Errorf("unexpected outlier result: %+v", res)
+	}
+	if res := report.Results[2]; res.Status != StatusFailed || res.Error != "rejected: price 0 is not positive" {
+		t.Errorf("unexpected result for a zero price: %+v", res)
+	}
+	if got := report.Quarantined(); len(got) != 1 || got[0] != "VOD.L" || report.Failed() != 1 {
+		t.Errorf("unexpected report: %+v", report.Results)
+	}
+
+	// Each quote of a symbol has its own baseline.
+	fx := []Source{{
+		Config:   config.ProviderConfig{Name: "fx", Pairs: []config.Pair{{From: "EUR", To: "USD"}, {From: "EUR", To: "GBP"}}},
+		Provider: mockRates{"EUR/USD": 1.178, "EUR/GBP": 0.865},
+	}}
+	w, q = &mockWriter{}, &mockWriter{}
+	New(fx, nil, w, testLogger(), WithOutliers(0.5, map[string]float64{"EUR/USD": 1.17, "EUR/GBP": 0.86}, q)).Run()
+	if len(w.records) != 2 || len(q.records) != 0 {
+		t.Errorf("unexpected records: %+v, quarantined %+v", w.records, q.records)
+	}
+
+	// Without a quarantine outliers are rejected.
+	w = &mockWriter{}
+	report = New(sources, nil, w, testLogger(), WithOutliers(0.5, last, nil)).Run()
+	if len(w.records) != 2 || report.Failed() != 2 || len(report.Quarantined()) != 0 {
+		t.Errorf("unexpected report: %+v", report.Results)
+	}
+}
+
+func TestRun_MinorUnits(t *testing.T) {
+	sources := []Source{
+		{
+			Config: config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{
+				{Symbol: "VOD.L"},
+				{Symbol: "NPN.JO", Currency: "ZAc"},
+				{Symbol: "AAPL", Currency: "GBX"},
+				{Symbol: "BARC.L"},
+			}},
+			Provider: &mockStocks{
+				prices:     map[string]float64{"VOD.L": 7215, "NPN.JO": 450000, "AAPL": 150, "BARC.L": 390},
+				currencies: map[string]string{"VOD.L": "GBp", "AAPL": "USD"},
+			},
+		},
+	}
+	w := &mockWriter{}
+	New(sources, nil, w, testLogger()).Run()
+
+	// BARC.L may be quoted in pence, pounds or dollars, so its price is not
+	// divided by 100 on the strength of its exchange alone.
+	want := []struct {
+		price    float64
+		currency string
+	}{{72.15, "GBP"}, {4500, "ZAR"}, {150, "USD"}, {390, DefaultCurrency}}
+	if len(w.records) != len(want) {
+		t.Fatalf("unexpected records: %+v", w.records)
+	}
+	for i, r := range w.records {
+		if math.Abs(r.Price-want[i].price) > 1e-9 || r.Currency != want[i].currency {
+			t.Errorf("unexpected record %+v, want %v %s", r, want[i].price, want[i].currency)
+		}
+	}
+	w = &mockWriter{}
+	report := New(sources, nil, w, testLogger(), WithCurrency("GBP", nil)).Run()
+	if res := report.Results[3]; res.Symbol != "BARC.L" || res.Error != "currency of BARC.L not known, set the currency of the stock" {
+		t.Errorf("unexpected result for BARC.L: %+v", res)
+	}
+
+	// stooq quotes London in pence.
+	client := doerFunc(func(*http.Request) (*http.Response, error) {
+		body := "Symbol,Date,Time,Open,High,Low,Close,Volume\r\nVOD.UK,2025-09-18,17:36:00,72,73,71,7215,100\r\n"
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+	sources = []Source{{
+		Config:   config.ProviderConfig{Name: "stooq", Stocks: []config.Stock{{Symbol: "VOD.L"}}},
+		Provider: stooq.New(client, testLogger()),
+	}}
+	w = &mockWriter{}
+	New(sources, nil, w, testLogger()).Run()
+	if len(w.records) != 1 || math.Abs(w.records[0].Price-72.15) > 1e-9 || w.records[0].Currency != "GBP" {
+		t.Errorf("unexpected records: %+v", w.records)
+	}
+}
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRun_Currency(t *testing.T) {
+	sources := []Source{
+		{
+			Config: config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{
+				{Symbol: "AAPL"}, {Symbol: "VOD.L"}, {Symbol: "SAP.DE"}, {Symbol: "NESN.SW"}, {Symbol: "FUND"},
+			}},
+			Provider: &mockStocks{
+				prices:     map[string]float64{"AAPL": 172.1, "VOD.L": 7215, "SAP.DE": 230, "NESN.SW": 80, "FUND": 10},
+				currencies: map[string]string{"AAPL": "USD", "VOD.L": "GBX", "SAP.DE": "EUR", "NESN.SW": "CHF"},
+			},
+		},
+		{
+			// Listed after the stocks, but fetched first.
+			Config:   config.ProviderConfig{Name: "fx", Pairs: []config.Pair{{From: "EUR", To: "USD"}}},
+			Provider: mockRates{"EUR/USD": 1.25},
+		},
+	}
+	known := []Rate{{From: "GBP", To: "USD", Rate: 1.5}, {From: "EUR", To: "USD", Rate: 1}}
+	w := &mockWriter{}
+	report := New(sources, nil, w, testLogger(), WithCurrency("EUR", known)).Run()
+
+	// FUND is in no known currency, so it cannot be converted.
+	want := []string{"EUR 1.25 USD", "AAPL 137.68 EUR", "VOD.L 86.58 EUR", "SAP.DE 230.00 EUR", "NESN.SW 80.00 CHF"}
+	if len(w.records) != len(want) {
+		t.Fatalf("unexpected records: %+v", w.records)
+	}
+	for i, r := range w.records {
+		if got := fmt.Sprintf("%s %.2f %s", r.Symbol, r.Price, r.Currency); got != want[i] {
+			t.Errorf("record %d: got %q, want %q", i, got, want[i])
+		}
+	}
+	if res := report.Results[5]; res.Symbol != "FUND" || res.Status != StatusFailed || res.Error != "currency of FUND not known, set the currency of the stock" {
+		t.Errorf("unexpected result for FUND: %+v", res)
+	}
+	if got := report.Unconverted(); len(got) != 1 || got[0] != "NESN.SW" || report.Results[4].Unconverted != "no rate from CHF to EUR" {
+		t.Errorf("expected NESN.SW to be reported unconverted: %+v", report.Results)
+	}
+}
+
+func TestRun_DerivedCurrency(t *testing.T) {
+	sources := []Source{
+		{
+			Config:   config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{{Symbol: "SAP.DE"}}},
+			Provider: &mockStocks{prices: map[string]float64{"SAP.DE": 230}},
+		},
+		{
+			Config:   config.ProviderConfig{Name: "fx", Pairs: []config.Pair{{From: "XAU", To: "USD"}, {From: "EUR", To: "USD"}}},
+			Provider: mockRates{"XAU/USD": 3110.35, "EUR/USD": 1.25},
+		},
+	}
+	derived := []config.DerivedConfig{
+		{Symbol: "GOLD_G", Expr: "XAU / 31.1035"},
+		{Symbol: "MIXED", Expr: "SAP.DE * XAU"},
+		{Symbol: "SAP_USD", Expr: "SAP.DE * EUR", Currency: "USD"},
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"unconverted", nil, []string{"GOLD_G 100.00 USD", "SAP_USD 287.50 USD"}},
+		{"converted", []Option{WithCurrency("EUR", nil)}, []string{"GOLD_G 80.00 EUR", "SAP_USD 230.00 EUR"}},
+	}
+	for _, tt := range tests {
+		w := &mockWriter{}
+		report := New(sources, derived, w, testLogger(), tt.opts...).Run()
+		var got []string
+		for _, r := range w.records {
+			if r.Provider == DerivedProvider {
+				got = append(got, fmt.Sprintf("%s %.2f %s", r.Symbol, r.Price, r.Currency))
+			}
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if report.Failed() != 1 || report.Results[4].Error != "inputs in EUR and USD, set the currency of the derived price" {
+			t.Errorf("%s: unexpected report: %+v", tt.name, report.Results)
+		}
+	}
+}
+
+func TestRun_DerivedPairs(t *testing.T) {
+	sources := []Source{{
+		Config:   config.ProviderConfig{Name: "fx", Pairs: []config.Pair{{From: "EUR", To: "USD"}, {From: "EUR", To: "GBP"}}},
+		Provider: mockRates{"EUR/USD": 1.25, "EUR/GBP": 0.86},
+	}}
+	derived := []config.DerivedConfig{
+		{Symbol: "USD_GBP", Expr: `"EUR/GBP" / "EUR/USD"`, Currency: "GBP"},
+	}
+	w := &mockWriter{}
+	New(sources, derived, w, testLogger()).Run()
+	if len(w.records) != 3 || w.records[2].Symbol != "USD_GBP" || math.Abs(w.records[2].Price-0.688) > 1e-9 {
+		t.Errorf("unexpected records: %+v", w.records)
+	}
+}
+
+func TestRun_DerivedChecks(t *testing.T) {
+	sources := []Source{{
+		Config:   config.ProviderConfig{Name: "fx", Pairs: []config.Pair{{From: "XAU", To: "USD"}}},
+		Provider: mockRates{"XAU/USD": 3110.35},
+	}}
+	derived := []config.DerivedConfig{
+		{Symbol: "DIV", Expr: "XAU / (XAU - XAU)"},
+		{Symbol: "ZERO", Expr: "XAU - XAU"},
+		{Symbol: "NEG", Expr: "-XAU"},
+		{Symbol: "HUGE", Expr: "XAU * " + strings.Repeat("9", 308)},
+		{Symbol: "GOLD_G", Expr: "XAU / 31.1035"},
+	}
+	w, q := &mockWriter{}, &mockWriter{}
+	report := New(sources, derived, w, testLogger(), WithOutliers(0.5, map[string]float64{"GOLD_G/USD": 10}, q)).Run()
+
+	if len(w.records) != 1 || w.records[0].Symbol != "XAU" {
+		t.Errorf("expected only XAU to be written, got %+v", w.records)
+	}
+	if len(q.records) != 1 || q.records[0].Symbol != "GOLD_G" || q.records[0].Note != "outlier: +900% from 10" {
+		t.Errorf("expected GOLD_G to be quarantined, got %+v", q.records)
+	}
+	want := []string{
+		`expression "XAU / (XAU - XAU)": division by zero`,
+		"rejected: price 0 is not positive",
+		"rejected: price -3110.35 is not positive",
+		"rejected: invalid price +Inf",
+	}
+	for i, w := range want {
+		if res := report.Results[i+1]; res.Status != StatusFailed || res.Error != w {
+			t.Errorf("result %d: got %+v, want error %q", i+1, res, w)
+		}
+	}
+	if res := report.Results[5]; res.Symbol != "GOLD_G" || res.Status != StatusQuarantined {
+		t.Errorf("unexpected result for GOLD_G: %+v", res)
+	}
+}
+
+func TestRates(t *testing.T) {
+	rs := rates{}
+	rs.add("EUR", "USD", 1.25)
+	rs.add("GBP", "USD", 1.5)
+	rs.add("XAU", "EUR", 2500)
+	tests := []struct {
+		from, to string
+		want     float64
+		ok       bool
+	}{
+		{"EUR", "USD", 1.25, true},
+		{"USD", "EUR", 0.8, true},
+		{"GBP", "EUR", 1.2, true},
+		{"XAU", "USD", 3125, true},
+		{"EUR", "EUR", 1, true},
+		{"CHF", "EUR", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := rs.rate(tt.from, tt.to)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("rate(%s, %s) = %v, %v, want %v, %v", tt.from, tt.to, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	sources := []Source{
+		{
+			Config:   config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{{Symbol: "AAPL"}}},
+			Provider: &mockStocks{prices: map[string]float64{"AAPL": 150}},
+		},
+		{
+			Config:   config.ProviderConfig{Name: "fx", Pairs: []config.Pair{{From: "EUR", To: "USD"}}},
+			Provider: mockCurrencies{},
+		},
+	}
+	w := &mockWriter{}
+	report := New(sources, nil, w, testLogger()).Backfill(day, day.AddDate(0, 0, 2))
+
+	if len(w.records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(w.records))
+	}
+	if !w.records[2].Time.Equal(day.AddDate(0, 0, 2)) {
+		t.Errorf("unexpected last record: %+v", w.records[2])
+	}
+	if len(report.Results) != 1 || !report.Results[0].Date.Equal(day.AddDate(0, 0, 2)) {
+		t.Errorf("unexpected report: %+v", report.Results)
+	}
+}
+
+func TestBackfill_Outliers(t *testing.T) {
+	sources := []Source{{
+		Config:   config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{{Symbol: "FUND"}}},
+		Provider: historyStocks{72.15, 7215, 0, 72.4},
+	}}
+	// Each price is judged against the one before it, not the latest in the
+	// price DB.
+	w, q := &mockWriter{}, &mockWriter{}
+	report := New(sources, nil, w, testLogger(), WithOutliers(0.5, map[string]float64{"FUND/EUR": 7300}, q)).Backfill(day, day.AddDate(0, 0, 3))
+	var got []float64
+	for _, r := range w.records {
+		got = append(got, r.Price)
+	}
+	if len(got) != 2 || got[0] != 72.15 || got[1] != 72.4 {
+		t.Errorf("unexpected prices written: %v", got)
+	}
+	if len(q.records) != 1 || q.records[0].Price != 7215 || report.Failed() != 1 {
+		t.Errorf("unexpected report: %+v", report.Results)
+	}
+}
+
+func TestBackfill_Currency(t *testing.T) {
+	sources := []Source{{
+		Config:   config.ProviderConfig{Name: "stocks", Stocks: []config.Stock{{Symbol: "SAP.DE"}, {Symbol: "AAPL.US"}, {Symbol: "FUND"}}},
+		Provider: historyStocks{10},
+	}}
+	w := &mockWriter{}
+	report := New(sources, nil, w, testLogger(), WithCurrency("EUR", []Rate{{From: "EUR", To: "USD", Rate: 1.25}})).Backfill(day, day)
+	if len(w.records) != 1 || w.records[0].Symbol != "SAP.DE" || w.records[0].Currency != "EUR" {
+		t.Errorf("unexpected records: %+v", w.records)
+	}
+	var errs []string
+	for _, res := range report.Results[1:] {
+		errs = append(errs, res.Error)
+	}
+	want := []string{"cannot convert historical prices from USD into EUR", "currency of FUND not known, set the currency of the stock"}
+	if strings.Join(errs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected errors: %q", errs)
+	}
+}
+
+// historyStocks returns its prices as the history of any symbol, one a day.
+type historyStocks []float64
+
+func (h historyStocks) FetchStock(symbol string) (*providers.StockData, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (h historyStocks) FetchStockRange(symbol string, from, to time.Time) ([]providers.StockData, error) {
+	data := make([]providers.StockData, len(h))
+	for i, p := range h {
+		data[i] = providers.StockData{Symbol: symbol, Date: from.AddDate(0, 0, i), Close: p}
+	}
+	return data, nil
+}
+
+func TestBuild(t *testing.T) {
+	cfg := &config.Config{Providers: []config.ProviderConfig{
+		{Name: "stooq", Stocks: []config.Stock{{Symbol: "AAPL"}}},
+		{Name: "fixer"}, // missing key
+		{Name: "nope"},
+		{Name: "manual", Options: manualOptions(t)},
+	}}
+	sources, errs := Build(cfg, nil, testLogger())
+	if len(sources) != 2 || sources[0].Config.Name != "stooq" {
+		t.Errorf("unexpected sources: %+v", sources)
+	}
+	if len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", errs)
+	}
+	if got := sources[0].Stocks(); len(got) != 1 || got[0].Symbol != "AAPL" {
+		t.Errorf("unexpected stocks: %v", got)
+	}
+	if got := sources[0].Pairs(); got != nil {
+		t.Errorf("expected no pairs for a stock provider, got %v", got)
+	}
+	if got := sources[1].Stocks(); len(got) != 1 || got[0].Symbol != "PENSION" {
+		t.Errorf("expected stocks listed by the provider, got %v", got)
+	}
+}
+
+func TestBuild_ErrorPosition(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "providers:\n  - name: fixer\n  - name: stooq\n    options: { currency: EUR }\n" +
+		"  - name: manual\n    options:\n      prices:\n        - { symbol: PENSION, price: 1 }\n" +
+		"ledger: { price_db: " + dir + "/prices.db }\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs := Build(cfg, nil, testLogger())
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+	if got := errs[0].Error(); got != path+":2:5: provider fixer: missing API key" {
+		t.Errorf("unexpected error %q", got)
+	}
+	if got := errs[1].Error(); !strings.Contains(got, path+`:4:16: unknown field "currency"`) {
+		t.Errorf("unexpected error %q", got)
+	}
+	if got := errs[2].Error(); got != path+":8:11: provider manual: options: prices[0]: date is required" {
+		t.Errorf("unexpected error %q", got)
+	}
+}
+
+func manualOptions(t *testing.T) yaml.Node {
+	t.Helper()
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(`prices: [{symbol: PENSION, price: 1, date: 2025-09-01}]`), &n); err != nil {
+		t.Fatal(err)
+	}
+	return *n.Content[0]
+}
@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/internal/config"
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+// staleStock returns why the price of stock dated date is stale, or "" if
+// it is not. Providers date end-of-day prices by the session they close, so
+// the date is compared as is rather than converted to the exchange time
+// zone, which could move midnight UTC to the day before.
+func (r *Runner) staleStock(stock config.Stock, date time.Time) string {
+	if r.staleness.Policy == "" {
+		return ""
+	}
+	if stock.MaxAge > 0 {
+		return r.staleAge(date, stock.MaxAge)
+	}
+	if reason := r.staleAge(date, r.staleness.MaxAge); reason != "" {
+		return reason
+	}
+	ex, ok := stock.Calendar()
+	if !ok {
+		return ""
+	}
+	want := ex.LastSession(r.now().Add(-r.staleness.Grace))
+	got := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, ex.Location())
+	if got.Before(want) {
+		return fmt.Sprintf("expected the close of %s", want.Format("2006-01-02"))
+	}
+	return ""
+}
+
+// staleAge returns why a price dated date is stale when it is older than
+// maxAge, falling back to the configured max_age when maxAge is zero.
+func (r *Runner) staleAge(date time.Time, maxAge time.Duration) string {
+	if r.staleness.Policy == "" {
+		return ""
+	}
+	if maxAge == 0 {
+		maxAge = r.staleness.MaxAge
+	}
+	if age := r.now().Sub(date); maxAge > 0 && age > maxAge {
+		return fmt.Sprintf("dated %s, older than %s", date.Format("2006-01-02"), maxAge)
+	}
+	return ""
+}
+
+// applyStaleness applies the staleness policy to a record about to be
+// written and reports whether it should still be written.
+func (r *Runner) applyStaleness(record *doctype.Record, symbol, provider, kind string, stale string) bool {
+	if stale == "" {
+		return true
+	}
+	switch r.staleness.Policy {
+	case config.StaleSkip:
+		r.logger.Info("skipped stale price", "symbol", symbol, "reason", stale)
+		r.report.add(symbol, provider, kind, record.Price, record.Time, StatusSkipped).Stale = stale
+		return false
+	case config.StaleTag:
+		record.Note = "stale: " + stale
+	default:
+		r.logger.Info("stale price", "symbol", symbol, "reason", stale)
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+// Package scheduler runs jobs on cron schedules for calais serve. It records
+// when each job last ran in a state file so that a run missed while the
+// daemon was down is made up on startup.
+package scheduler
+
+import (
+	"context"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/cron"
+	"git.sr.ht/~atmosx/calais/pkg/log"
+)
+
+// maxSleep bounds a single wait so that a run is not delayed long after a
+// system suspend, during which timers do not advance.
+const maxSleep = time.Minute
+
+// Job is a function run on a schedule. Each run starts after a random delay
+// of up to Jitter.
+type Job struct {
+	Name     string
+	Schedule *cron.Schedule
+	Jitter   time.Duration
+	Run      func()
+}
+
+type Scheduler struct {
+	jobs      []Job
+	statePath string
+	logger    *log.Logger
+
+	// now, after and jitter are replaced in tests.
+	now    func() time.Time
+	after  func(time.Duration) <-chan time.Time
+	jitter func(max time.Duration) time.Duration
+}
+
+func New(jobs []Job, statePath string, logger *log.Logger) *Scheduler {
+	return &Scheduler{
+		jobs:      jobs,
+		statePath: statePath,
+		logger:    logger,
+		now:       time.Now,
+		after:     time.After,
+		jitter:    randomJitter,
+	}
+}
+
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
+
+// DefaultStatePath returns $XDG_STATE_HOME/calais/serve.json, with
+// ~/.local/state as the default state home.
+func DefaultStatePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "calais-serve.json"
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "calais", "serve.json")
+}
+
+// Run runs the jobs until ctx is cancelled. Jobs whose last recorded run is
+// older than their previous activation run once right away. Jobs never run
+// concurrently, so they do not write the price DB at the same time, and a
+// job running when ctx is cancelled is finished before Run returns.
+func (s *Scheduler) Run(ctx context.Context) error {
+	st, err := loadState(s.statePath)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	for _, j := range s.jobs {
+		last, ok := st.LastRun[j.Name]
+		if !ok {
+			// A new job starts its history now rather than catching up.
+			st.LastRun[j.Name] = now
+			continue
+		}
+		if next := j.Schedule.Next(last); !next.IsZero() && !next.After(now) {
+			s.logger.Info("catching up on missed run", "schedule", j.Name, "missed", next)
+			s.run(j, st)
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
+	if err := st.save(s.statePath); err != nil {
+		return err
+	}
+
+	planned := map[string]time.Time{}
+	for ctx.Err() == nil {
+		var (
+			job *Job
+			at  time.Time
+		)
+		for i := range s.jobs {
+			j := &s.jobs[i]
+			t, ok := planned[j.Name]
+			if !ok {
+				next := j.Schedule.Next(s.now())
+				if next.IsZero() {
+					continue
+				}
+				t = next.Add(s.jitter(j.Jitter))
+				planned[j.Name] = t
+				s.logger.Debug("scheduled run", "schedule", j.Name, "at", t)
+			}
+			if job == nil || t.Before(at) {
+				job, at = j, t
+			}
+		}
+		if job == nil {
+			s.logger.Info("no schedule fires again")
+			<-ctx.Done()
+			return nil
+		}
+
+		wait := at.Sub(s.now())
+		if wait > maxSleep {
+			wait = maxSleep
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.after(wait):
+		}
+		if s.now().Before(at) {
+			continue
+		}
+		delete(planned, job.Name)
+		s.run(*job, st)
+	}
+	return nil
+}
+
+// run runs j and records it in the state file. A state file that cannot be
+// written only costs the catch-up after a restart, so it does not stop the
+// daemon.
+func (s *Scheduler) run(j Job, st *state) {
+	s.logger.Info("starting scheduled run", "schedule", j.Name)
+	st.LastRun[j.Name] = s.now()
+	j.Run()
+	if err := st.save(s.statePath); err != nil {
+		s.logger.Error("failed to save scheduler state", "error", err)
+	}
+}
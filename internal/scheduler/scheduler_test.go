@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/cron"
+	"git.sr.ht/~atmosx/calais/pkg/log"
+)
+
+var start = time.Date(2025, 9, 18, 10, 30, 0, 0, time.UTC)
+
+// fakeClock advances its time by the duration waited for.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) after(d time.Duration) <-chan time.Time {
+	c.t = c.t.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.t
+	return ch
+}
+
+func schedule(t *testing.T, spec string) *cron.Schedule {
+	t.Helper()
+	s, err := cron.ParseInLocation(spec, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestScheduler returns a scheduler on a fake clock whose jobs record the
+// time they ran and which stops after runs runs.
+func newTestScheduler(t *testing.T, statePath string, runs int, jobs ...Job) (*Scheduler, *[]string) {
+	t.Helper()
+	clock := &fakeClock{t: start}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var ran []string
+	for i := range jobs {
+		name := jobs[i].Name
+		jobs[i].Run = func() {
+			ran = append(ran, name+"@"+clock.now().Format("02T15:04:05"))
+			if len(ran) == runs {
+				cancel()
+			}
+		}
+	}
+	s := New(jobs, statePath, log.New(io.Discard, "Error"))
+	s.now, s.after = clock.now, clock.after
+	s.jitter = func(max time.Duration) time.Duration { return max }
+	go func() {
+		<-time.After(5 * time.Second)
+		cancel()
+	}()
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return s, &ran
+}
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "serve.json")
+	_, ran := newTestScheduler(t, path, 4,
+		Job{Name: "hourly", Schedule: schedule(t, "0 * * * *")},
+		Job{Name: "close", Schedule: schedule(t, "30 11 * * *"), Jitter: 90 * time.Second},
+	)
+	want := []string{"hourly@18T11:00:00", "close@18T11:31:30", "hourly@18T12:00:00", "hourly@18T13:00:00"}
+	if len(*ran) != len(want) {
+		t.Fatalf("expected runs %v, got %v", want, *ran)
+	}
+	for i := range want {
+		if (*ran)[i] != want[i] {
+			t.Errorf("run %d: expected %s, got %s", i, want[i], (*ran)[i])
+		}
+	}
+
+	st, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := st.LastRun["close"]; !got.Equal(time.Date(2025, 9, 18, 11, 31, 30, 0, time.UTC)) {
+		t.Errorf("unexpected recorded run %v", got)
+	}
+}
+
+func TestRun_CatchUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serve.json")
+	st := &state{LastRun: map[string]time.Time{
+		// Missed the run at 22:00 yesterday.
+		"daily": start.Add(-14 * time.Hour),
+		// Ran after its last activation.
+		"weekly": start.Add(-time.Hour),
+	}}
+	if err := st.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ran := newTestScheduler(t, path, 2,
+		Job{Name: "daily", Schedule: schedule(t, "0 22 * * *")},
+		Job{Name: "weekly", Schedule: schedule(t, "0 9 * * 4")},
+	)
+	want := []string{"daily@18T10:30:00", "daily@18T22:00:00"}
+	if len(*ran) != 2 || (*ran)[0] != want[0] || (*ran)[1] != want[1] {
+		t.Errorf("expected runs %v, got %v", want, *ran)
+	}
+}
+
+func TestRun_NewJobDoesNotCatchUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serve.json")
+	_, ran := newTestScheduler(t, path, 1, Job{Name: "daily", Schedule: schedule(t, "0 9 * * *")})
+	if len(*ran) != 1 || (*ran)[0] != "daily@19T09:00:00" {
+		t.Errorf("expected the first run at the next activation, got %v", *ran)
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	dir := t.TempDir()
+	st, err := loadState(filepath.Join(dir, "missing.json"))
+	if err != nil || len(st.LastRun) != 0 {
+		t.Errorf("expected an empty state for a missing file, got %+v, %v", st, err)
+	}
+
+	path := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadState(path); err == nil {
+		t.Error("expected an error for a malformed state file")
+	}
+}
+
+func TestDefaultStatePath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+	if got := DefaultStatePath(); got != "/state/calais/serve.json" {
+		t.Errorf("unexpected path %q", got)
+	}
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/me")
+	if got := DefaultStatePath(); got != "/home/me/.local/state/calais/serve.json" {
+		t.Errorf("unexpected path %q", got)
+	}
+}
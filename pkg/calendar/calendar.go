@@ -0,0 +1,178 @@
+// Package calendar knows the trading sessions and holidays of major stock
+// exchanges, so that callers can tell whether and when a fresh closing price
+// is expected for a symbol. Exchanges are found by the marketstack-style
+// ticker suffixes used in the configuration, e.g. .DE for Xetra.
+//
+// Holidays are computed from rules rather than listed per year. Early
+// closes and one-off closures, such as national days of mourning, are not
+// known.
+package calendar
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// Exchange time zones must be known even where the system has no zone
+	// database.
+	_ "time/tzdata"
+)
+
+// Exchange is a stock exchange with a single daily session.
+type Exchange struct {
+	// Code is the ISO 10383 market identifier code, e.g. XNYS.
+	Code     string
+	Name     string
+	Timezone string
+	// Currency is the currency most of its listings are quoted in, GBX for
+	// London.
+	Currency string
+	// Open and Close are the session times as offsets from local midnight.
+	Open, Close time.Duration
+
+	suffixes []string
+	holidays []holiday
+
+	once sync.Once
+	loc  *time.Location
+}
+
+// Location returns the time zone of the exchange.
+func (e *Exchange) Location() *time.Location {
+	e.once.Do(func() {
+		loc, err := time.LoadLocation(e.Timezone)
+		if err != nil {
+			// The embedded zone database makes this unreachable.
+			loc = time.UTC
+		}
+		e.loc = loc
+	})
+	return e.loc
+}
+
+// date returns midnight of the day of t in the exchange time zone.
+func (e *Exchange) date(t time.Time) time.Time {
+	t = t.In(e.Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, e.Location())
+}
+
+// Holidays returns the weekdays of year on which the exchange is closed, in
+// order, as midnight in the exchange time zone.
+func (e *Exchange) Holidays(year int) []time.Time {
+	days := map[time.Time]bool{}
+	for _, h := range e.holidays {
+		month, day, ok := h.date(year)
+		if !ok {
+			continue
+		}
+		d := time.Date(year, month, day, 0, 0, 0, 0, e.Location())
+		if d = h.observe(d, days); !weekend(d) {
+			days[d] = true
+		}
+	}
+	list := make([]time.Time, 0, len(days))
+	for d := range days {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Before(list[j]) })
+	return list
+}
+
+// IsTradingDay reports whether the exchange has a session on the day of t
+// in its time zone.
+func (e *Exchange) IsTradingDay(t time.Time) bool {
+	d := e.date(t)
+	if weekend(d) {
+		return false
+	}
+	for _, h := range e.Holidays(d.Year()) {
+		if h.Equal(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsOpen reports whether the exchange is in session at t.
+func (e *Exchange) IsOpen(t time.Time) bool {
+	if !e.IsTradingDay(t) {
+		return false
+	}
+	d := e.date(t)
+	return !t.Before(e.at(d, e.Open)) && t.Before(e.at(d, e.Close))
+}
+
+// LastSession returns the day of the latest session that closed at or before
+// t, as midnight in the exchange time zone. Its close is the freshest price
+// available at t.
+func (e *Exchange) LastSession(t time.Time) time.Time {
+	d := e.date(t)
+	if !t.Before(e.at(d, e.Close)) && e.IsTradingDay(d) {
+		return d
+	}
+	for {
+		d = time.Date(d.Year(), d.Month(), d.Day()-1, 0, 0, 0, 0, e.Location())
+		if e.IsTradingDay(d) {
+			return d
+		}
+	}
+}
+
+// NextClose returns the first session close after t.
+func (e *Exchange) NextClose(t time.Time) time.Time {
+	d := e.date(t)
+	for {
+		if close := e.at(d, e.Close); e.IsTradingDay(d) && close.After(t) {
+			return close
+		}
+		d = time.Date(d.Year(), d.Month(), d.Day()+1, 0, 0, 0, 0, e.Location())
+	}
+}
+
+// at returns the wall clock time offset on day d, which is correct on days
+// when daylight saving time changes.
+func (e *Exchange) at(d time.Time, offset time.Duration) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, int(offset/time.Minute), 0, 0, e.Location())
+}
+
+func weekend(d time.Time) bool {
+	return d.Weekday() == time.Saturday || d.Weekday() == time.Sunday
+}
+
+// Exchanges returns the known exchanges ordered by code.
+func Exchanges() []*Exchange {
+	list := make([]*Exchange, len(exchanges))
+	copy(list, exchanges)
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// Lookup returns the exchange with the market identifier code.
+func Lookup(code string) (*Exchange, bool) {
+	for _, e := range exchanges {
+		if strings.EqualFold(e.Code, code) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// ForSymbol returns the exchange a ticker is listed on, judged by its
+// suffix. Tickers without a suffix, which could be anything from a US
+// listing to a hand-kept fund, have no exchange.
+func ForSymbol(symbol string) (*Exchange, bool) {
+	i := strings.LastIndex(symbol, ".")
+	if i < 0 {
+		return nil, false
+	}
+	suffix := symbol[i+1:]
+	for _, e := range exchanges {
+		for _, s := range e.suffixes {
+			if strings.EqualFold(s, suffix) {
+				return e, true
+			}
+		}
+	}
+	return nil, false
+}
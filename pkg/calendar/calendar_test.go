@@ -0,0 +1,133 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func dates(list []time.Time) string {
+	s := make([]string, len(list))
+	for i, d := range list {
+		s[i] = d.Format("01-02")
+	}
+	return strings.Join(s, " ")
+}
+
+func TestHolidays(t *testing.T) {
+	tests := []struct {
+		code string
+		year int
+		want string
+	}{
+		{"XNYS", 2025, "01-01 01-20 02-17 04-18 05-26 06-19 07-04 09-01 11-27 12-25"},
+		// New Year's Day on a Saturday is not observed; Juneteenth and
+		// Christmas are.
+		{"XNYS", 2022, "01-17 02-21 04-15 05-30 06-20 07-04 09-05 11-24 12-26"},
+		{"XNYS", 2021, "01-01 01-18 02-15 04-02 05-31 07-05 09-06 11-25 12-24"},
+		{"XETR", 2025, "01-01 04-18 04-21 05-01 12-24 12-25 12-26 12-31"},
+		{"XLON", 2025, "01-01 04-18 04-21 05-05 05-26 08-25 12-25 12-26"},
+		// Christmas on a Saturday and Boxing Day on a Sunday.
+		{"XLON", 2021, "01-01 04-02 04-05 05-03 05-31 08-30 12-27 12-28"},
+		{"XPAR", 2025, "01-01 04-18 04-21 05-01 12-25 12-26"},
+		{"XSWX", 2025, "01-01 01-02 04-18 04-21 05-01 05-29 06-09 08-01 12-24 12-25 12-26 12-31"},
+		// Orthodox Easter on 12 April.
+		{"XATH", 2026, "01-01 01-06 02-23 03-25 04-10 04-13 05-01 06-01 10-28 12-24 12-25"},
+	}
+	for _, tt := range tests {
+		e, ok := Lookup(tt.code)
+		if !ok {
+			t.Fatalf("Lookup(%q) failed", tt.code)
+		}
+		if got := dates(e.Holidays(tt.year)); got != tt.want {
+			t.Errorf("%s %d holidays:\n got %s\nwant %s", tt.code, tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestEaster(t *testing.T) {
+	for year, want := range map[int]string{2024: "03-31", 2025: "04-20", 2026: "04-05", 2038: "04-25"} {
+		if got := westernEaster(year).Format("01-02"); got != want {
+			t.Errorf("westernEaster(%d) = %s, want %s", year, got, want)
+		}
+	}
+	for year, want := range map[int]string{2024: "05-05", 2025: "04-20", 2026: "04-12", 2027: "05-02"} {
+		if got := orthodoxEaster(year).Format("01-02"); got != want {
+			t.Errorf("orthodoxEaster(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
+
+func TestForSymbol(t *testing.T) {
+	tests := map[string]string{
+		"MSFT.US": "XNYS",
+		"SAP.DE":  "XETR",
+		"sxr8.de": "XETR",
+		"VOD.L":   "XLON",
+		"TITC.AT": "XATH",
+		"NESN.SW": "XSWX",
+		"ASML.AS": "XPAR",
+	}
+	for symbol, want := range tests {
+		e, ok := ForSymbol(symbol)
+		if !ok || e.Code != want {
+			t.Errorf("ForSymbol(%q) = %v, %v, want %s", symbol, e, ok, want)
+		}
+	}
+	if e, ok := ForSymbol("7203.T"); ok {
+		t.Errorf("expected no exchange for an unknown suffix, got %s", e.Code)
+	}
+	if e, _ := ForSymbol("VOD.L"); e.Currency != "GBX" {
+		t.Errorf("expected London quotes in GBX, got %q", e.Currency)
+	}
+	if e, ok := ForSymbol("AAPL"); ok {
+		t.Errorf("expected no exchange without a suffix, got %s", e.Code)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	nyse, _ := Lookup("XNYS")
+	ny := nyse.Location()
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2025, month, day, hour, min, 0, 0, ny)
+	}
+
+	tests := []struct {
+		name    string
+		t       time.Time
+		trading bool
+		open    bool
+		last    string
+		next    time.Time
+	}{
+		{"before the open", at(9, 18, 9, 0), true, false, "09-17", at(9, 18, 16, 0)},
+		{"in session", at(9, 18, 12, 0), true, true, "09-17", at(9, 18, 16, 0)},
+		{"at the close", at(9, 18, 16, 0), true, false, "09-18", at(9, 19, 16, 0)},
+		{"on a Saturday", at(9, 20, 12, 0), false, false, "09-19", at(9, 22, 16, 0)},
+		{"on Good Friday", at(4, 18, 17, 0), false, false, "04-17", at(4, 21, 16, 0)},
+	}
+	for _, tt := range tests {
+		if got := nyse.IsTradingDay(tt.t); got != tt.trading {
+			t.Errorf("%s: IsTradingDay() = %v", tt.name, got)
+		}
+		if got := nyse.IsOpen(tt.t); got != tt.open {
+			t.Errorf("%s: IsOpen() = %v", tt.name, got)
+		}
+		if got := nyse.LastSession(tt.t).Format("01-02"); got != tt.last {
+			t.Errorf("%s: LastSession() = %s, want %s", tt.name, got, tt.last)
+		}
+		if got := nyse.NextClose(tt.t); !got.Equal(tt.next) {
+			t.Errorf("%s: NextClose() = %v, want %v", tt.name, got, tt.next)
+		}
+	}
+
+	// Sessions are in the exchange's time zone: 23:00 in Athens is 16:00 in
+	// New York on the same day.
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nyse.LastSession(time.Date(2025, 9, 18, 23, 0, 0, 0, athens)).Format("01-02"); got != "09-18" {
+		t.Errorf("LastSession() across time zones = %s", got)
+	}
+}
@@ -0,0 +1,260 @@
+package calendar
+
+import "time"
+
+// holiday is a rule for a yearly holiday. date returns its nominal date and
+// observe moves it when it falls on a weekend.
+type holiday struct {
+	date    func(year int) (time.Month, int, bool)
+	observe func(d time.Time, taken map[time.Time]bool) time.Time
+}
+
+// Observance rules. Holidays are added in the order they are listed, so
+// that a substitute day skips holidays listed before it.
+var (
+	// asIs keeps the date; on a weekend the holiday has no effect.
+	asIs = func(d time.Time, _ map[time.Time]bool) time.Time { return d }
+
+	// nearestWeekday moves Saturday to Friday and Sunday to Monday, as in
+	// the US.
+	nearestWeekday = func(d time.Time, _ map[time.Time]bool) time.Time {
+		switch d.Weekday() {
+		case time.Saturday:
+			return d.AddDate(0, 0, -1)
+		case time.Sunday:
+			return d.AddDate(0, 0, 1)
+		}
+		return d
+	}
+
+	// sundayToMonday moves Sunday to Monday but drops Saturday, like the
+	// NYSE does for New Year's Day.
+	sundayToMonday = func(d time.Time, _ map[time.Time]bool) time.Time {
+		if d.Weekday() == time.Sunday {
+			return d.AddDate(0, 0, 1)
+		}
+		return d
+	}
+
+	// nextFreeWeekday moves a weekend holiday to the next weekday that is
+	// not a holiday yet, as UK substitute bank holidays do.
+	nextFreeWeekday = func(d time.Time, taken map[time.Time]bool) time.Time {
+		for weekend(d) || taken[d] {
+			d = d.AddDate(0, 0, 1)
+		}
+		return d
+	}
+)
+
+func fixed(month time.Month, day int, observe func(time.Time, map[time.Time]bool) time.Time) holiday {
+	return holiday{
+		date:    func(int) (time.Month, int, bool) { return month, day, true },
+		observe: observe,
+	}
+}
+
+// nth is the n-th weekday of month, counting from the end when n is
+// negative.
+func nth(n int, weekday time.Weekday, month time.Month) holiday {
+	return holiday{
+		date: func(year int) (time.Month, int, bool) {
+			if n > 0 {
+				first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+				day := 1 + (int(weekday)-int(first.Weekday())+7)%7 + (n-1)*7
+				return month, day, true
+			}
+			last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+			day := last.Day() - (int(last.Weekday())-int(weekday)+7)%7 + (n+1)*7
+			return month, day, true
+		},
+		observe: asIs,
+	}
+}
+
+// easter is offset days from Western Easter Sunday, e.g. -2 for Good Friday.
+func easter(offset int) holiday {
+	return holiday{
+		date: func(year int) (time.Month, int, bool) {
+			d := westernEaster(year).AddDate(0, 0, offset)
+			return d.Month(), d.Day(), true
+		},
+		observe: asIs,
+	}
+}
+
+// orthodox is offset days from Orthodox Easter Sunday.
+func orthodox(offset int) holiday {
+	return holiday{
+		date: func(year int) (time.Month, int, bool) {
+			d := orthodoxEaster(year).AddDate(0, 0, offset)
+			return d.Month(), d.Day(), true
+		},
+		observe: asIs,
+	}
+}
+
+// since limits h to the years from first on.
+func since(first int, h holiday) holiday {
+	date := h.date
+	h.date = func(year int) (time.Month, int, bool) {
+		if year < first {
+			return 0, 0, false
+		}
+		return date(year)
+	}
+	return h
+}
+
+// westernEaster computes the Gregorian Easter Sunday with the anonymous
+// Gregorian algorithm.
+func westernEaster(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// orthodoxEaster computes the Julian Easter Sunday with Meeus' algorithm and
+// converts it to the Gregorian calendar, valid from 1900 to 2099.
+func orthodoxEaster(year int) time.Time {
+	a, b, c := year%4, year%7, year%19
+	d := (19*c + 15) % 30
+	e := (2*a + 4*b - d + 34) % 7
+	month := (d + e + 114) / 31
+	day := (d+e+114)%31 + 1
+	return time.Date(year, time.Month(month), day+13, 0, 0, 0, 0, time.UTC)
+}
+
+var exchanges = []*Exchange{
+	{
+		Code:     "XNYS",
+		Name:     "New York Stock Exchange",
+		Timezone: "America/New_York",
+		Currency: "USD",
+		Open:     9*time.Hour + 30*time.Minute,
+		Close:    16 * time.Hour,
+		suffixes: []string{"US", "NYSE", "NASDAQ", "XNAS", "XNYS"},
+		holidays: []holiday{
+			fixed(time.January, 1, sundayToMonday),
+			nth(3, time.Monday, time.January),  // Martin Luther King Jr. Day
+			nth(3, time.Monday, time.February), // Washington's Birthday
+			easter(-2),                         // Good Friday
+			nth(-1, time.Monday, time.May),     // Memorial Day
+			since(2022, fixed(time.June, 19, nearestWeekday)),
+			fixed(time.July, 4, nearestWeekday),
+			nth(1, time.Monday, time.September),  // Labor Day
+			nth(4, time.Thursday, time.November), // Thanksgiving
+			fixed(time.December, 25, nearestWeekday),
+		},
+	},
+	{
+		Code:     "XETR",
+		Name:     "Xetra",
+		Timezone: "Europe/Berlin",
+		Currency: "EUR",
+		Open:     9 * time.Hour,
+		Close:    17*time.Hour + 30*time.Minute,
+		suffixes: []string{"DE", "F", "XETRA", "DEX"},
+		holidays: []holiday{
+			fixed(time.January, 1, asIs),
+			easter(-2),
+			easter(1),
+			fixed(time.May, 1, asIs),
+			fixed(time.December, 24, asIs),
+			fixed(time.December, 25, asIs),
+			fixed(time.December, 26, asIs),
+			fixed(time.December, 31, asIs),
+		},
+	},
+	{
+		Code:     "XLON",
+		Name:     "London Stock Exchange",
+		Timezone: "Europe/London",
+		Currency: "GBX",
+		Open:     8 * time.Hour,
+		Close:    16*time.Hour + 30*time.Minute,
+		suffixes: []string{"L", "LON", "XLON", "UK"},
+		holidays: []holiday{
+			fixed(time.January, 1, nextFreeWeekday),
+			easter(-2),
+			easter(1),
+			nth(1, time.Monday, time.May),     // Early May bank holiday
+			nth(-1, time.Monday, time.May),    // Spring bank holiday
+			nth(-1, time.Monday, time.August), // Summer bank holiday
+			fixed(time.December, 25, nextFreeWeekday),
+			fixed(time.December, 26, nextFreeWeekday),
+		},
+	},
+	{
+		Code:     "XPAR",
+		Name:     "Euronext",
+		Timezone: "Europe/Paris",
+		Currency: "EUR",
+		Open:     9 * time.Hour,
+		Close:    17*time.Hour + 30*time.Minute,
+		suffixes: []string{"PA", "AS", "BR", "LS", "XPAR", "XAMS"},
+		holidays: []holiday{
+			fixed(time.January, 1, asIs),
+			easter(-2),
+			easter(1),
+			fixed(time.May, 1, asIs),
+			fixed(time.December, 25, asIs),
+			fixed(time.December, 26, asIs),
+		},
+	},
+	{
+		Code:     "XSWX",
+		Name:     "SIX Swiss Exchange",
+		Timezone: "Europe/Zurich",
+		Currency: "CHF",
+		Open:     9 * time.Hour,
+		Close:    17*time.Hour + 30*time.Minute,
+		suffixes: []string{"SW", "XSWX"},
+		holidays: []holiday{
+			fixed(time.January, 1, asIs),
+			fixed(time.January, 2, asIs),
+			easter(-2),
+			easter(1),
+			fixed(time.May, 1, asIs),
+			easter(39), // Ascension Day
+			easter(50), // Whit Monday
+			fixed(time.August, 1, asIs),
+			fixed(time.December, 24, asIs),
+			fixed(time.December, 25, asIs),
+			fixed(time.December, 26, asIs),
+			fixed(time.December, 31, asIs),
+		},
+	},
+	{
+		Code:     "XATH",
+		Name:     "Athens Stock Exchange",
+		Timezone: "Europe/Athens",
+		Currency: "EUR",
+		Open:     10 * time.Hour,
+		Close:    17*time.Hour + 20*time.Minute,
+		suffixes: []string{"AT", "ATH", "XATH"},
+		holidays: []holiday{
+			fixed(time.January, 1, asIs),
+			fixed(time.January, 6, asIs), // Epiphany
+			orthodox(-48),                // Clean Monday
+			fixed(time.March, 25, asIs),  // Independence Day
+			orthodox(-2),                 // Good Friday
+			orthodox(1),                  // Easter Monday
+			fixed(time.May, 1, asIs),
+			orthodox(50), // Whit Monday
+			fixed(time.August, 15, asIs),
+			fixed(time.October, 28, asIs),
+			fixed(time.December, 24, asIs),
+			fixed(time.December, 25, asIs),
+			fixed(time.December, 26, asIs),
+		},
+	},
+}
@@ -0,0 +1,77 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// Thursday.
+	from := time.Date(2025, 9, 18, 17, 40, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 9, 18, 17, 41, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 9, 18, 17, 45, 0, 0, time.UTC)},
+		{"30 17 * * 1-5", time.Date(2025, 9, 19, 17, 30, 0, 0, time.UTC)},
+		{"30 17 * * sat,sun", time.Date(2025, 9, 20, 17, 30, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, 9, 21, 9, 0, 0, 0, time.UTC)},
+		{"0 8-18/4 * * *", time.Date(2025, 9, 19, 8, 0, 0, 0, time.UTC)},
+		{"5 0 1 jan *", time.Date(2026, 1, 1, 0, 5, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 9, 18, 18, 0, 0, 0, time.UTC)},
+		// Day of month or day of week when both are restricted.
+		{"0 0 1 * mon", time.Date(2025, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := ParseInLocation(tt.spec, time.UTC)
+		if err != nil {
+			t.Errorf("ParseInLocation(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next() = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestNext_Location(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	s, err := ParseInLocation("15 16 * * 1-5", ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Next(time.Date(2025, 9, 18, 12, 0, 0, 0, time.UTC))
+	if want := time.Date(2025, 9, 18, 20, 15, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+	if got.Location() != ny {
+		t.Errorf("expected the schedule's location, got %v", got.Location())
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@often",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q): expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package beancount writes prices as beancount price directives, such as
+// "2025-09-18 price AAPL 172.1 USD". Beancount dates prices by day, so the
+// time of day is dropped.
+package beancount
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+// commodity matches the names beancount accepts for commodities and
+// currencies: capital letters, digits and ' . _ -, starting with a letter and
+// ending with a letter or digit, at most 24 characters long.
+var commodity = regexp.MustCompile(`^[A-Z]([A-Z0-9'._-]{0,22}[A-Z0-9])?$`)
+
+// Writer writes price directives.
+type Writer struct {
+	w   io.Writer
+	loc *time.Location
+}
+
+// New returns a writer of prices to w, dated by their day in loc as
+// described by doctype.In.
+func New(w io.Writer, loc *time.Location) *Writer {
+	return &Writer{w: w, loc: loc}
+}
+
+// Append writes the price of r. Prices of symbols beancount cannot name,
+// such as 7203.T, and prices without a currency are errors.
+func (w *Writer) Append(r doctype.Record) error {
+	if !commodity.MatchString(r.Symbol) {
+		return fmt.Errorf("%q is not a beancount commodity name", r.Symbol)
+	}
+	if !commodity.MatchString(r.Currency) {
+		return fmt.Errorf("%s: currency %q is not a beancount commodity name", r.Symbol, r.Currency)
+	}
+	_, err := fmt.Fprintf(w.w, "%s price %s %s %s\n",
+		doctype.In(r.Time, w.loc).Format("2006-01-02"), r.Symbol, strconv.FormatFloat(r.Price, 'f', -1, 64), r.Currency)
+	return err
+}
@@ -0,0 +1,47 @@
+package beancount
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+func TestWriter(t *testing.T) {
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	w := New(&b, athens)
+	records := []doctype.Record{
+		{Time: time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC), Symbol: "AAPL", Price: 172.1, Currency: "USD"},
+		{Time: time.Date(2025, 9, 18, 22, 30, 0, 0, time.UTC), Symbol: "EUR", Price: 1.17755, Currency: "USD"},
+		{Time: time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC), Symbol: "SXR8.DE", Price: 595.22, Currency: "EUR"},
+	}
+	for _, r := range records {
+		if err := w.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// End-of-day prices keep their day; the rate fetched late on the 18th
+	// UTC is of the 19th in Athens.
+	want := "2025-09-18 price AAPL 172.1 USD\n" +
+		"2025-09-19 price EUR 1.17755 USD\n" +
+		"2025-09-18 price SXR8.DE 595.22 EUR\n"
+	if got := b.String(); got != want {
+		t.Errorf("unexpected prices:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, r := range []doctype.Record{
+		{Symbol: "7203.T", Price: 2500, Currency: "JPY"},
+		{Symbol: "VWCE 2", Price: 120, Currency: "EUR"},
+		{Symbol: "aapl", Price: 172.1, Currency: "USD"},
+		{Symbol: "AAPL", Price: 172.1},
+	} {
+		if err := w.Append(r); err == nil {
+			t.Errorf("expected an error for %+v", r)
+		}
+	}
+}
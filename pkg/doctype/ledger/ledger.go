@@ -3,32 +3,243 @@ package ledger
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"git.sr.ht/~atmosx/calais/pkg/doctype"
 )
 
-type Writer struct{ filePath string }
+type Writer struct {
+	filePath string
+	dedup    bool
+	loc      *time.Location
+	dates    doctype.Dates
+}
+
+// Option configures a Writer.
+type Option func(*Writer)
 
-func NewWriter(filePath string) *Writer { return &Writer{filePath: filePath} }
+// WithDedup makes the writer replace the prices of the same symbol in the
+// same quote on the same day instead of appending next to them.
+func WithDedup() Option {
+	return func(w *Writer) { w.dedup = true }
+}
+
+// WithTimes writes the time of each price in loc, as described by
+// doctype.In, and with or without the time of day following dates. By
+// default times are written in their own time zone, with the time of day.
+func WithTimes(loc *time.Location, dates doctype.Dates) Option {
+	return func(w *Writer) { w.loc, w.dates = loc, dates }
+}
+
+func NewWriter(filePath string, opts ...Option) *Writer {
+	w := &Writer{filePath: filePath}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
 
 func (w *Writer) Append(r doctype.Record) error {
+	line, err := w.format(r)
+	if err != nil {
+		return err
+	}
+	return w.appendLine(line)
+}
+
+// AppendEntry writes a price directive read from another price DB as it
+// was, without its comment.
+func (w *Writer) AppendEntry(e Entry) error {
+	line := e.Text
+	if i := strings.Index(line, ";"); i >= 0 {
+		line = line[:i]
+	}
+	return w.appendLine(strings.TrimSpace(line) + "\n")
+}
+
+// AppendAll writes records at once, which with dedup is much faster than
+// appending them one by one: the price DB is rewritten only once. The
+// outcome is the same. Nothing is written when a record cannot be formatted.
+func (w *Writer) AppendAll(records []doctype.Record) error {
+	lines := make([]string, 0, len(records))
+	for _, r := range records {
+		line, err := w.format(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.Symbol, err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	if !w.dedup {
+		return w.appendLine(strings.Join(lines, ""))
+	}
+
+	data, err := os.ReadFile(w.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	merged := mergeDedup(splitLines(string(data)), lines)
+	return WriteFileAtomic(w.filePath, []byte(strings.Join(merged, "")))
+}
+
+func (w *Writer) appendLine(line string) error {
+	if w.dedup {
+		return w.replace(line)
+	}
+
 	f, err := os.OpenFile(w.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	var line string
+	_, err = f.WriteString(line)
+	return err
+}
+
+// currencySymbols are the currencies whose symbol is written in front of the
+// amount, as is common in ledger files. Other currencies follow the amount
+// as their code, e.g. 12.50 CHF.
+var currencySymbols = map[string]string{
+	"EUR": "€",
+	"USD": "$",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+// DefaultCurrency returns the currency a price of kind without one is
+// written in: dollars for currency rates and euros for anything else, as
+// calais always wrote them.
+func DefaultCurrency(kind string) string {
+	if kind == "currency" {
+		return "USD"
+	}
+	return "EUR"
+}
+
+func (w *Writer) format(r doctype.Record) (string, error) {
+	var decimals int
 	switch r.Kind {
 	case "currency":
-		line = fmt.Sprintf("P %s %s $%.6f\n",
-			r.Time.Format("2006/01/02 15:04:05"), r.Symbol, r.Price)
+		decimals = 6
 	case "commodity":
-		line = fmt.Sprintf("P %s %s €%.2f\n",
-			r.Time.Format("2006/01/02 15:04:05"), r.Symbol, r.Price)
+		decimals = 2
 	default:
-		return fmt.Errorf("unknown kind %q", r.Kind)
+		return "", fmt.Errorf("unknown kind %q", r.Kind)
 	}
-	_, err = f.WriteString(line)
-	return err
+	currency := r.Currency
+	if currency == "" {
+		currency = DefaultCurrency(r.Kind)
+	}
+	layout := "2006/01/02 15:04:05"
+	if w.dates.DateOnly(r.Time) {
+		layout = "2006/01/02"
+	}
+	line := fmt.Sprintf("P %s %s %s",
+		doctype.In(r.Time, w.loc).Format(layout), r.Symbol, formatAmount(r.Price, currency, decimals))
+	if r.Note != "" {
+		line += " ; " + strings.ReplaceAll(r.Note, "\n", " ")
+	}
+	return line + "\n", nil
+}
+
+func formatAmount(price float64, currency string, decimals int) string {
+	if symbol, ok := currencySymbols[currency]; ok {
+		return fmt.Sprintf("%s%.*f", symbol, decimals, price)
+	}
+	return fmt.Sprintf("%.*f %s", decimals, price, currency)
+}
+
+// replace drops the entries superseded by line and appends it.
+func (w *Writer) replace(line string) error {
+	data, err := os.ReadFile(w.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := applyDedup(splitLines(string(data)), strings.TrimSuffix(line, "\n"))
+	return WriteFileAtomic(w.filePath, []byte(strings.Join(lines, "")))
+}
+
+// applyDedup returns lines without the entries of the same symbol, quote and
+// day as line, followed by line. Lines keep their trailing newline.
+func applyDedup(lines []string, line string) []string {
+	e, err := ParseLine(line)
+	if err != nil {
+		return append(lines, line+"\n")
+	}
+	out := lines[:0:0]
+	for _, l := range lines {
+		if supersedes(e, l) {
+			continue
+		}
+		out = append(out, l)
+	}
+	if n := len(out); n > 0 && !strings.HasSuffix(out[n-1], "\n") {
+		out[n-1] += "\n"
+	}
+	return append(out, line+"\n")
+}
+
+// mergeDedup is applyDedup for many new lines: the old lines without the
+// entries of the same symbol, quote and day as one of added, followed by the
+// last of added for each symbol, quote and day.
+func mergeDedup(lines, added []string) []string {
+	key := func(line string) (string, bool) {
+		text := strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(text, "P ") && !strings.HasPrefix(text, "P\t") {
+			return "", false
+		}
+		e, err := ParseLine(text)
+		if err != nil {
+			return "", false
+		}
+		return e.dayKey(), true
+	}
+	last := map[string]int{}
+	keys := make([]string, len(added))
+	for i, l := range added {
+		if k, ok := key(l); ok {
+			keys[i], last[k] = k, i
+		}
+	}
+
+	out := lines[:0:0]
+	for _, l := range lines {
+		if k, ok := key(l); ok {
+			if _, replaced := last[k]; replaced {
+				continue
+			}
+		}
+		out = append(out, l)
+	}
+	if n := len(out); n > 0 && !strings.HasSuffix(out[n-1], "\n") {
+		out[n-1] += "\n"
+	}
+	for i, l := range added {
+		if keys[i] == "" || last[keys[i]] == i {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// supersedes reports whether e replaces the price directive on line.
+func supersedes(e Entry, line string) bool {
+	text := strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(text, "P ") && !strings.HasPrefix(text, "P\t") {
+		return false
+	}
+	old, err := ParseLine(text)
+	return err == nil && old.dayKey() == e.dayKey()
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if n := len(lines); lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	return lines
 }
@@ -40,6 +40,39 @@ func TestWriter_Append(t *testing.T) {
 			expected: "P 2025/08/19 14:30:00 AAPL €150.75\n",
 			wantErr:  false,
 		},
+		{
+			name: "commodity in dollars",
+			record: doctype.Record{
+				Time:     now,
+				Symbol:   "AAPL",
+				Price:    172.1,
+				Kind:     "commodity",
+				Currency: "USD",
+			},
+			expected: "P 2025/08/19 14:30:00 AAPL $172.10\n",
+		},
+		{
+			name: "currency in francs",
+			record: doctype.Record{
+				Time:     now,
+				Symbol:   "EUR",
+				Price:    0.9312,
+				Kind:     "currency",
+				Currency: "CHF",
+			},
+			expected: "P 2025/08/19 14:30:00 EUR 0.931200 CHF\n",
+		},
+		{
+			name: "note",
+			record: doctype.Record{
+				Time:   now,
+				Symbol: "AAPL",
+				Price:  150.75,
+				Kind:   "commodity",
+				Note:   "stale: expected the close of 2025-08-20",
+			},
+			expected: "P 2025/08/19 14:30:00 AAPL €150.75 ; stale: expected the close of 2025-08-20\n",
+		},
 		{
 			name: "unknown kind",
 			record: doctype.Record{
@@ -93,3 +126,165 @@ func TestWriter_FileCreation(t *testing.T) {
 		t.Error("expected file to be created")
 	}
 }
+
+func TestWriter_Dedup(t *testing.T) {
+	tmp := filepath.Join(t.TempDir(), "prices.db")
+	existing := "; comment\n" +
+		"P 2025/08/19 00:00:00 AAPL €149.00\n" +
+		"P 2025/08/18 00:00:00 AAPL €148.00\n" +
+		"P 2025/08/19 00:00:00 MSFT €400.00"
+	if err := os.WriteFile(tmp, []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWriter(tmp, WithDedup())
+	if err := w.Append(doctype.Record{
+		Time:   time.Date(2025, 8, 19, 14, 30, 0, 0, time.UTC),
+		Symbol: "AAPL",
+		Price:  150.75,
+		Kind:   "commodity",
+	}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	got, err := os.ReadFile(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "; comment\n" +
+		"P 2025/08/18 00:00:00 AAPL €148.00\n" +
+		"P 2025/08/19 00:00:00 MSFT €400.00\n" +
+		"P 2025/08/19 14:30:00 AAPL €150.75\n"
+	if string(got) != want {
+		t.Errorf("unexpected file content:\ngot:  %q\nwant: %q", string(got), want)
+	}
+}
+
+func TestWriter_DedupCrossRates(t *testing.T) {
+	at := time.Date(2025, 9, 19, 8, 29, 7, 0, time.Local)
+	records := []doctype.Record{
+		{Time: at, Symbol: "EUR", Price: 1.1775, Kind: "currency", Currency: "USD"},
+		{Time: at, Symbol: "EUR", Price: 0.865, Kind: "currency", Currency: "GBP"},
+		{Time: at.Add(time.Hour), Symbol: "EUR", Price: 1.178, Kind: "currency", Currency: "USD"},
+	}
+	want := "P 2025/09/19 08:29:07 EUR £0.865000\n" +
+		"P 2025/09/19 09:29:07 EUR $1.178000\n"
+
+	dir := t.TempDir()
+	one, all := filepath.Join(dir, "one.db"), filepath.Join(dir, "all.db")
+	w := NewWriter(one, WithDedup())
+	for _, r := range records {
+		if err := w.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := NewWriter(all, WithDedup()).AppendAll(records); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{one, all} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s: a rate in another quote must not replace the first one:\n%s", filepath.Base(path), got)
+		}
+	}
+}
+
+func TestWriter_AppendAll(t *testing.T) {
+	existing := "; comment\n" +
+		"P 2025/08/19 00:00:00 AAPL €149.00\n" +
+		"P 2025/08/18 00:00:00 AAPL €148.00\n" +
+		"P 2025/08/19 00:00:00 MSFT €400.00"
+	at := func(day, hour int) time.Time { return time.Date(2025, 8, day, hour, 0, 0, 0, time.Local) }
+	records := []doctype.Record{
+		{Time: at(19, 10), Symbol: "AAPL", Price: 150, Kind: "commodity"},
+		{Time: at(20, 0), Symbol: "AAPL", Price: 151, Kind: "commodity"},
+		{Time: at(19, 16), Symbol: "AAPL", Price: 152, Kind: "commodity"},
+		{Time: at(19, 16), Symbol: "EUR", Price: 1.17, Kind: "currency"},
+	}
+
+	// AppendAll leaves the file Append would, with and without dedup.
+	for _, opts := range [][]Option{nil, {WithDedup()}} {
+		dir := t.TempDir()
+		one, all := filepath.Join(dir, "one.db"), filepath.Join(dir, "all.db")
+		for _, path := range []string{one, all} {
+			if err := os.WriteFile(path, []byte(existing), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w := NewWriter(one, opts...)
+		for _, r := range records {
+			if err := w.Append(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := NewWriter(all, opts...).AppendAll(records); err != nil {
+			t.Fatalf("AppendAll: %v", err)
+		}
+		want, _ := os.ReadFile(one)
+		got, _ := os.ReadFile(all)
+		if string(got) != string(want) {
+			t.Errorf("dedup %v: AppendAll wrote\n%s\nwant\n%s", len(opts) > 0, got, want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "prices.db")
+	bad := append(records[:1:1], doctype.Record{Time: at(19, 0), Symbol: "X", Price: 1, Kind: "bond"})
+	if err := NewWriter(path).AppendAll(bad); err == nil {
+		t.Error("expected an error for an unknown kind")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written after an error")
+	}
+}
+
+func TestWriter_AppendEntry(t *testing.T) {
+	tmp := filepath.Join(t.TempDir(), "prices.db")
+	e, err := ParseLine("P 2025/08/19 00:00:00 AAPL €15075.00 ; outlier: up 9900%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewWriter(tmp).AppendEntry(e); err != nil {
+		t.Fatalf("AppendEntry: %v", err)
+	}
+	got, err := os.ReadFile(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "P 2025/08/19 00:00:00 AAPL €15075.00\n"; string(got) != want {
+		t.Errorf("unexpected file content %q, want %q", got, want)
+	}
+}
+
+func TestWriter_Times(t *testing.T) {
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixer := time.Date(2025, 9, 19, 8, 29, 7, 0, time.UTC)
+	eod := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		dates doctype.Dates
+		want  string
+	}{
+		{doctype.Timestamps, "P 2025/09/19 11:29:07 EUR $1.177550\nP 2025/09/18 00:00:00 AAPL €150.75\n"},
+		{doctype.DatesOnly, "P 2025/09/19 EUR $1.177550\nP 2025/09/18 AAPL €150.75\n"},
+		{doctype.DatesAuto, "P 2025/09/19 11:29:07 EUR $1.177550\nP 2025/09/18 AAPL €150.75\n"},
+	}
+	for _, tt := range tests {
+		tmp := filepath.Join(t.TempDir(), "prices.db")
+		w := NewWriter(tmp, WithTimes(athens, tt.dates))
+		if err := w.Append(doctype.Record{Time: fixer, Symbol: "EUR", Price: 1.17755, Kind: "currency"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Append(doctype.Record{Time: eod, Symbol: "AAPL", Price: 150.75, Kind: "commodity"}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: unexpected file content:\ngot:  %q\nwant: %q", tt.dates, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package ledger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// Preview is a doctype.PriceWriter that records what a Writer would do
+// without touching the price DB. Diff renders the outcome.
+type Preview struct {
+	w     *Writer
+	lines []string
+}
+
+// NewPreview returns a preview of the writes of w.
+func NewPreview(w *Writer) *Preview {
+	return &Preview{w: w}
+}
+
+func (p *Preview) Append(r doctype.Record) error {
+	line, err := p.w.format(r)
+	if err != nil {
+		return err
+	}
+	p.lines = append(p.lines, strings.TrimSuffix(line, "\n"))
+	return nil
+}
+
+// Lines returns the price directives that were appended.
+func (p *Preview) Lines() []string {
+	return p.lines
+}
+
+// Diff writes a unified diff between the current price DB and the one the
+// writer would leave behind, including the lines replaced under dedup.
+func (p *Preview) Diff(out io.Writer) error {
+	data, err := os.ReadFile(p.w.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	old := splitLines(string(data))
+
+	type line struct {
+		text    string
+		old     int // index in old, or -1 for appended lines
+		removed bool
+	}
+	result := make([]*line, 0, len(old)+len(p.lines))
+	for i, l := range old {
+		result = append(result, &line{text: l, old: i})
+	}
+	for _, l := range p.lines {
+		if p.w.dedup {
+			if e, err := ParseLine(l); err == nil {
+				for _, r := range result {
+					if !r.removed && supersedes(e, r.text) {
+						r.removed = true
+					}
+				}
+			}
+		}
+		result = append(result, &line{text: l + "\n", old: -1})
+	}
+
+	var ops []diffOp
+	for _, r := range result {
+		switch {
+		case r.old >= 0 && r.removed:
+			ops = append(ops, diffOp{kind: '-', text: r.text})
+		case r.old >= 0:
+			ops = append(ops, diffOp{kind: ' ', text: r.text})
+		case !r.removed:
+			ops = append(ops, diffOp{kind: '+', text: r.text})
+		}
+	}
+	return writeUnified(out, p.w.filePath, ops)
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// writeUnified renders ops, a full edit script from the old to the new file,
+// as a unified diff. Nothing is written when there are no changes.
+func writeUnified(out io.Writer, path string, ops []diffOp) error {
+	var changed []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(out, "--- %s\n+++ %s\n", path, path); err != nil {
+		return err
+	}
+
+	// Line numbers before each op in the old and new file.
+	oldAt := make([]int, len(ops)+1)
+	newAt := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if op.kind != '+' {
+			oldAt[i+1]++
+		}
+		if op.kind != '-' {
+			newAt[i+1]++
+		}
+	}
+
+	for i := 0; i < len(changed); {
+		start := max(changed[i]-diffContext, 0)
+		end := changed[i] + 1
+		for i++; i < len(changed) && changed[i]-end <= 2*diffContext; i++ {
+			end = changed[i] + 1
+		}
+		end = min(end+diffContext, len(ops))
+
+		oldLen, newLen := oldAt[end]-oldAt[start], newAt[end]-newAt[start]
+		if _, err := fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldAt[start], oldLen), hunkRange(newAt[start], newLen)); err != nil {
+			return err
+		}
+		for _, op := range ops[start:end] {
+			text := op.text
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n\\ No newline at end of file\n"
+			}
+			if _, err := fmt.Fprintf(out, "%c%s", op.kind, text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
@@ -0,0 +1,94 @@
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+func TestPreview_Diff(t *testing.T) {
+	var existing strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&existing, "P 2025/08/%02d 00:00:00 AAPL €%d.00\n", i, 100+i)
+	}
+	tmp := filepath.Join(t.TempDir(), "prices.db")
+	if err := os.WriteFile(tmp, []byte(existing.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWriter(tmp, WithDedup())
+	p := NewPreview(w)
+	for _, r := range []doctype.Record{
+		{Time: time.Date(2025, 8, 2, 18, 0, 0, 0, time.UTC), Symbol: "AAPL", Price: 99, Kind: "commodity"},
+		{Time: time.Date(2025, 8, 11, 0, 0, 0, 0, time.UTC), Symbol: "AAPL", Price: 111, Kind: "commodity"},
+		{Time: time.Date(2025, 8, 11, 12, 0, 0, 0, time.UTC), Symbol: "EUR", Price: 1.1, Kind: "currency"},
+	} {
+		if err := p.Append(r); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := p.Diff(&buf); err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := "--- " + tmp + "\n+++ " + tmp + "\n" +
+		"@@ -1,5 +1,4 @@\n" +
+		" P 2025/08/01 00:00:00 AAPL €101.00\n" +
+		"-P 2025/08/02 00:00:00 AAPL €102.00\n" +
+		" P 2025/08/03 00:00:00 AAPL €103.00\n" +
+		" P 2025/08/04 00:00:00 AAPL €104.00\n" +
+		" P 2025/08/05 00:00:00 AAPL €105.00\n" +
+		"@@ -8,3 +7,6 @@\n" +
+		" P 2025/08/08 00:00:00 AAPL €108.00\n" +
+		" P 2025/08/09 00:00:00 AAPL €109.00\n" +
+		" P 2025/08/10 00:00:00 AAPL €110.00\n" +
+		"+P 2025/08/02 18:00:00 AAPL €99.00\n" +
+		"+P 2025/08/11 00:00:00 AAPL €111.00\n" +
+		"+P 2025/08/11 12:00:00 EUR $1.100000\n"
+	if buf.String() != want {
+		t.Errorf("unexpected diff:\ngot:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if len(p.Lines()) != 3 {
+		t.Errorf("expected 3 lines, got %v", p.Lines())
+	}
+
+	got, _ := os.ReadFile(tmp)
+	if string(got) != existing.String() {
+		t.Error("preview modified the price DB")
+	}
+}
+
+func TestPreview_NewFile(t *testing.T) {
+	tmp := filepath.Join(t.TempDir(), "prices.db")
+	p := NewPreview(NewWriter(tmp))
+	if err := p.Append(doctype.Record{Time: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), Symbol: "X", Price: 1, Kind: "commodity"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Append(doctype.Record{Symbol: "X", Kind: "invalid"}); err == nil {
+		t.Error("expected error for unknown kind, got nil")
+	}
+
+	var buf bytes.Buffer
+	if err := p.Diff(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "--- " + tmp + "\n+++ " + tmp + "\n@@ -0,0 +1 @@\n+P 2025/08/01 00:00:00 X €1.00\n"
+	if buf.String() != want {
+		t.Errorf("unexpected diff:\n%s", buf.String())
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Error("preview created the price DB")
+	}
+
+	buf.Reset()
+	if err := NewPreview(NewWriter(tmp)).Diff(&buf); err != nil || buf.Len() != 0 {
+		t.Errorf("expected empty diff, got %q, %v", buf.String(), err)
+	}
+}
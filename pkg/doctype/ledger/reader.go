@@ -0,0 +1,316 @@
+package ledger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Entry is a price directive read from a ledger price DB.
+type Entry struct {
+	Line   int       // 1-based line number
+	Time   time.Time // in the time zone the price DB was read in
+	Symbol string    // priced commodity, e.g. AAPL
+	Price  float64
+	Quote  string // commodity of the price, e.g. € or USD
+	Text   string // the line as read
+}
+
+// Currency returns the ISO 4217 code of the quote, or "" when the quote is
+// not a currency calais knows.
+func (e Entry) Currency() string {
+	for code, symbol := range currencySymbols {
+		if e.Quote == symbol {
+			return code
+		}
+	}
+	if len(e.Quote) == 3 && strings.ToUpper(e.Quote) == e.Quote {
+		return e.Quote
+	}
+	return ""
+}
+
+// Day returns the date of the entry, used to tell duplicates apart.
+func (e Entry) Day() string { return e.Time.Format("2006-01-02") }
+
+// dayKey identifies the price of a symbol in a quote on a day, of which
+// ledger uses only the last one. Prices of the same symbol in different
+// quotes, such as EUR in dollars and in pounds, are distinct.
+func (e Entry) dayKey() string {
+	return e.quoteKey() + "\x00" + e.Day()
+}
+
+// quoteKey identifies the prices of a symbol in a quote.
+func (e Entry) quoteKey() string {
+	quote := e.Currency()
+	if quote == "" {
+		quote = e.Quote
+	}
+	return e.Symbol + "\x00" + quote
+}
+
+// ParseError reports a malformed price directive.
+type ParseError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v: %q", e.Line, e.Err, e.Text)
+}
+
+// Parse reads the price directives of a price DB in the local time zone, as
+// ledger does. Other lines, such as comments, are ignored. Malformed
+// directives are returned as *ParseError values next to the entries that
+// could be read.
+func Parse(r io.Reader) ([]Entry, []error, error) {
+	return ParseIn(r, time.Local)
+}
+
+// ParseIn is like Parse but reads the times of the price DB in loc, such
+// as the ledger.timezone they were written in.
+func ParseIn(r io.Reader, loc *time.Location) ([]Entry, []error, error) {
+	var (
+		entries []Entry
+		errs    []error
+	)
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if !strings.HasPrefix(line, "P ") && !strings.HasPrefix(line, "P\t") {
+			continue
+		}
+		e, err := ParseLineIn(line, loc)
+		if err != nil {
+			errs = append(errs, &ParseError{Line: n, Text: line, Err: err})
+			continue
+		}
+		e.Line = n
+		entries = append(entries, e)
+	}
+	return entries, errs, s.Err()
+}
+
+// ReadFile parses the price DB at path. A missing file holds no entries.
+func ReadFile(path string) ([]Entry, []error, error) {
+	return ReadFileIn(path, time.Local)
+}
+
+// ReadFileIn is like ReadFile but reads times in loc.
+func ReadFileIn(path string, loc *time.Location) ([]Entry, []error, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return ParseIn(f, loc)
+}
+
+// ParseLine parses a single P directive such as
+// "P 2025/09/18 00:00:00 TITC.AT €36.20" or "P 2025-09-18 EUR 1.17 USD".
+func ParseLine(line string) (Entry, error) {
+	return ParseLineIn(line, time.Local)
+}
+
+// ParseLineIn is like ParseLine but reads the time in loc.
+func ParseLineIn(line string, loc *time.Location) (Entry, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "P"))
+	if i := strings.Index(rest, ";"); i >= 0 {
+		rest = strings.TrimSpace(rest[:i])
+	}
+
+	var field string
+	field, rest = next(rest)
+	day, err := parseDay(field)
+	if err != nil {
+		return Entry{}, err
+	}
+	clock := "00:00:00"
+	if f, r := next(rest); strings.Contains(f, ":") {
+		clock, rest = f, r
+		if strings.Count(clock, ":") == 1 {
+			clock += ":00"
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", day+" "+clock, loc)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid time %q", clock)
+	}
+
+	symbol, rest := nextCommodity(rest)
+	if symbol == "" {
+		return Entry{}, fmt.Errorf("missing commodity")
+	}
+	price, quote, err := parseAmount(rest)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Time: t, Symbol: symbol, Price: price, Quote: quote, Text: line}, nil
+}
+
+func parseDay(s string) (string, error) {
+	s = strings.ReplaceAll(s, "/", "-")
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q", s)
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+func next(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
+
+func nextCommodity(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `"`) {
+		if end := strings.Index(s[1:], `"`); end >= 0 {
+			return s[1 : end+1], strings.TrimSpace(s[end+2:])
+		}
+	}
+	return next(s)
+}
+
+// parseAmount reads "€36.20", "$1.177550", "-1,234.5 USD" or "36.20 EUR".
+func parseAmount(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, "", fmt.Errorf("missing price")
+	}
+	isNum := func(r rune) bool { return unicode.IsDigit(r) || r == '.' || r == ',' || r == '-' }
+
+	var number, quote string
+	if i := strings.IndexFunc(s, isNum); i > 0 {
+		quote = strings.Trim(strings.TrimSpace(s[:i]), `"`)
+		number = strings.TrimSpace(s[i:])
+	} else {
+		end := strings.IndexFunc(s, func(r rune) bool { return !isNum(r) })
+		if end < 0 {
+			end = len(s)
+		}
+		number = s[:end]
+		quote = strings.Trim(strings.TrimSpace(s[end:]), `"`)
+	}
+	price, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", ""), 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid price %q", s)
+	}
+	return price, quote, nil
+}
+
+// Duplicates returns the entries superseded by a later entry for the same
+// symbol in the same quote on the same day. Ledger uses the last price of a
+// day, so these are the ones that can be dropped without changing any
+// valuation.
+func Duplicates(entries []Entry) []Entry {
+	last := make(map[string]int, len(entries))
+	for i, e := range entries {
+		last[e.dayKey()] = i
+	}
+	var dups []Entry
+	for i, e := range entries {
+		if last[e.dayKey()] != i {
+			dups = append(dups, e)
+		}
+	}
+	return dups
+}
+
+// Latest returns the most recent entry of each symbol in each quote, in
+// order of first appearance. Of entries at the same time the last one wins,
+// as in ledger.
+func Latest(entries []Entry) []Entry {
+	index := map[string]int{}
+	var out []Entry
+	for _, e := range entries {
+		i, ok := index[e.quoteKey()]
+		if !ok {
+			index[e.quoteKey()] = len(out)
+			out = append(out, e)
+			continue
+		}
+		if !e.Time.Before(out[i].Time) {
+			out[i] = e
+		}
+	}
+	return out
+}
+
+// Rewrite replaces the price DB at path with its content minus the price
+// directives for which drop returns true. Other lines are kept as they are.
+// The file is replaced atomically.
+func Rewrite(path string, drop func(Entry) bool) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		b       strings.Builder
+		dropped int
+	)
+	lines := strings.SplitAfter(string(data), "\n")
+	for n, line := range lines {
+		text := strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(text, "P ") || strings.HasPrefix(text, "P\t") {
+			if e, err := ParseLine(text); err == nil {
+				e.Line = n + 1
+				if drop(e) {
+					dropped++
+					continue
+				}
+			}
+		}
+		b.WriteString(line)
+	}
+	if dropped == 0 {
+		return 0, nil
+	}
+	return dropped, WriteFileAtomic(path, []byte(b.String()))
+}
+
+// WriteFileAtomic replaces the file at path with data through a temporary
+// file, so that readers never see a partial file. An existing file keeps its
+// permissions.
+func WriteFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".calais-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
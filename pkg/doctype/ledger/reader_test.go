@@ -0,0 +1,155 @@
+package ledger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const priceDB = `; prices written by calais
+P 2025/09/17 00:00:00 SXR8.DE €595.22
+P 2025/09/18 00:00:00 TITC.AT €36.20
+P 2025/09/19 08:29:07 EUR $1.177550
+P 2025-09-19 "VWCE 2" 1,234.5 EUR
+P 2025/09/18 12:00 TITC.AT €36.40 ; corrected
+P 2025/13/01 X €1
+P 2025/09/18 Y
+`
+
+func TestParse(t *testing.T) {
+	entries, errs, err := Parse(strings.NewReader(priceDB))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d: %+v", len(entries), entries)
+	}
+
+	want := []struct {
+		line   int
+		symbol string
+		price  float64
+		quote  string
+		time   time.Time
+	}{
+		{2, "SXR8.DE", 595.22, "€", time.Date(2025, 9, 17, 0, 0, 0, 0, time.Local)},
+		{3, "TITC.AT", 36.20, "€", time.Date(2025, 9, 18, 0, 0, 0, 0, time.Local)},
+		{4, "EUR", 1.17755, "$", time.Date(2025, 9, 19, 8, 29, 7, 0, time.Local)},
+		{5, "VWCE 2", 1234.5, "EUR", time.Date(2025, 9, 19, 0, 0, 0, 0, time.Local)},
+		{6, "TITC.AT", 36.40, "€", time.Date(2025, 9, 18, 12, 0, 0, 0, time.Local)},
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Line != w.line || e.Symbol != w.symbol || e.Price != w.price || e.Quote != w.quote || !e.Time.Equal(w.time) {
+			t.Errorf("entry %d = %+v; want %+v", i, e, w)
+		}
+	}
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 parse errors, got %v", errs)
+	}
+	var pe *ParseError
+	if !errors.As(errs[0], &pe) || pe.Line != 7 {
+		t.Errorf("unexpected first error: %v", errs[0])
+	}
+}
+
+func TestParseIn(t *testing.T) {
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _, err := ParseIn(strings.NewReader(priceDB), athens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2025, 9, 19, 8, 29, 7, 0, athens)
+	if e := entries[2]; !e.Time.Equal(want) || e.Time.Location() != athens {
+		t.Errorf("EUR read at %v, want %v", e.Time, want)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	entries, _, _ := Parse(strings.NewReader(priceDB))
+	dups := Duplicates(entries)
+	if len(dups) != 1 || dups[0].Line != 3 {
+		t.Errorf("unexpected duplicates: %+v", dups)
+	}
+
+	// Rates of a currency in different quotes are distinct prices; the
+	// same quote written as symbol or code is not.
+	entries, _, _ = Parse(strings.NewReader("P 2025/09/19 EUR $1.1775\n" +
+		"P 2025/09/19 EUR £0.8650\n" +
+		"P 2025/09/19 12:00 EUR 1.1780 USD\n"))
+	dups = Duplicates(entries)
+	if len(dups) != 1 || dups[0].Line != 1 {
+		t.Errorf("unexpected duplicates across quotes: %+v", dups)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	entries, _, _ := Parse(strings.NewReader(priceDB))
+	latest := Latest(entries)
+	var got []string
+	for _, e := range latest {
+		got = append(got, e.Text)
+	}
+	want := []string{
+		"P 2025/09/17 00:00:00 SXR8.DE €595.22",
+		"P 2025/09/18 12:00 TITC.AT €36.40 ; corrected",
+		"P 2025/09/19 08:29:07 EUR $1.177550",
+		`P 2025-09-19 "VWCE 2" 1,234.5 EUR`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected latest entries:\n%s", strings.Join(got, "\n"))
+	}
+
+	// Each quote of a symbol has its own latest entry.
+	entries, _, _ = Parse(strings.NewReader("P 2025/09/18 EUR $1.17\nP 2025/09/19 EUR £0.86\nP 2025/09/19 EUR 1.18 USD\n"))
+	if latest = Latest(entries); len(latest) != 2 || latest[0].Line != 3 || latest[1].Line != 2 {
+		t.Errorf("unexpected latest entries across quotes: %+v", latest)
+	}
+}
+
+func TestEntry_Currency(t *testing.T) {
+	for quote, want := range map[string]string{"€": "EUR", "$": "USD", "£": "GBP", "CHF": "CHF", "EUR": "EUR", "shares": "", "": ""} {
+		if got := (Entry{Quote: quote}).Currency(); got != want {
+			t.Errorf("Currency() of %q = %q, want %q", quote, got, want)
+		}
+	}
+}
+
+func TestReadFile_Missing(t *testing.T) {
+	entries, errs, err := ReadFile(filepath.Join(t.TempDir(), "missing.db"))
+	if err != nil || len(entries) != 0 || len(errs) != 0 {
+		t.Errorf("ReadFile on missing file = %v, %v, %v", entries, errs, err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.db")
+	if err := os.WriteFile(path, []byte(priceDB), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Rewrite(path, func(e Entry) bool { return e.Symbol == "TITC.AT" })
+	if err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 dropped entries, got %d", n)
+	}
+	got, _ := os.ReadFile(path)
+	if strings.Contains(string(got), "TITC.AT") {
+		t.Errorf("TITC.AT not dropped:\n%s", got)
+	}
+	if !strings.HasPrefix(string(got), "; prices written by calais\n") || !strings.Contains(string(got), "P 2025/09/18 Y\n") {
+		t.Errorf("other lines not kept:\n%s", got)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+		t.Errorf("mode not kept: %v", fi.Mode())
+	}
+}
@@ -11,4 +11,28 @@ type Record struct {
 	Symbol string
 	Price  float64
 	Kind   string
+	// Currency is the ISO 4217 code the price is in, empty when unknown.
+	Currency string
+	// Provider is the name of the provider instance the price came from,
+	// empty when unknown.
+	Provider string
+	// Note is an optional comment written next to the price.
+	Note string
+}
+
+// Tee returns a writer that appends each record to all of writers in turn,
+// stopping at the first error.
+func Tee(writers ...PriceWriter) PriceWriter {
+	return tee(writers)
+}
+
+type tee []PriceWriter
+
+func (t tee) Append(r Record) error {
+	for _, w := range t {
+		if err := w.Append(r); err != nil {
+			return err
+		}
+	}
+	return nil
 }
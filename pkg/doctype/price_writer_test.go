@@ -37,3 +37,63 @@ func TestPriceWriterInterface(t *testing.T) {
 		t.Errorf("unexpected record flow: %+v", w.calls)
 	}
 }
+
+func TestIn(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		t    time.Time
+		loc  *time.Location
+		want string
+	}{
+		{"instant", time.Date(2025, 9, 18, 8, 29, 7, 0, time.UTC), athens, "2025-09-18 11:29:07 EEST"},
+		{"date keeps its day", time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC), ny, "2025-09-18 00:00:00 EDT"},
+		{"date with an offset", time.Date(2025, 9, 18, 0, 0, 0, 0, athens), time.UTC, "2025-09-18 00:00:00 UTC"},
+		{"no location", time.Date(2025, 9, 18, 8, 0, 0, 0, athens), nil, "2025-09-18 08:00:00 EEST"},
+	}
+	for _, tt := range tests {
+		if got := In(tt.t, tt.loc).Format("2006-01-02 15:04:05 MST"); got != tt.want {
+			t.Errorf("%s: In() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDates_DateOnly(t *testing.T) {
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	instant := day.Add(14 * time.Hour)
+	tests := []struct {
+		dates        Dates
+		day, instant bool
+	}{
+		{Timestamps, false, false},
+		{"", false, false},
+		{DatesOnly, true, true},
+		{DatesAuto, true, false},
+	}
+	for _, tt := range tests {
+		if got := tt.dates.DateOnly(day); got != tt.day {
+			t.Errorf("%q: DateOnly(day) = %v", tt.dates, got)
+		}
+		if got := tt.dates.DateOnly(instant); got != tt.instant {
+			t.Errorf("%q: DateOnly(instant) = %v", tt.dates, got)
+		}
+	}
+}
+
+func TestTee(t *testing.T) {
+	a, b := &mockWriter{}, &mockWriter{}
+	r := Record{Time: time.Date(2025, 8, 19, 12, 0, 0, 0, time.UTC), Symbol: "AAPL", Price: 150.75, Kind: "commodity"}
+	if err := Tee(a, b).Append(r); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.calls) != 1 || len(b.calls) != 1 || a.calls[0] != r || b.calls[0] != r {
+		t.Errorf("expected the record in both writers, got %+v and %+v", a.calls, b.calls)
+	}
+}
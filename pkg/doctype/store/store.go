@@ -0,0 +1,221 @@
+// Package store keeps the price history in an embedded bbolt database. It is
+// a doctype.PriceWriter, and unlike a ledger price DB it can be queried by
+// symbol and date, so that price files in any format can be regenerated
+// from it.
+//
+// Each symbol has a bucket of prices per quote currency, keyed by their time
+// in UTC, which keeps them in order and makes a price of the same symbol in
+// the same quote at the same time replace the earlier one, while EUR in
+// dollars and EUR in pounds are kept apart. A second bucket indexes the
+// prices by the day they are dated, in their own time zone, symbol and
+// quote.
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+var (
+	symbolsBucket = []byte("symbols")
+	daysBucket    = []byte("days")
+)
+
+// keyLayout orders keys by time when compared as bytes.
+const keyLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Store is a price history database.
+type Store struct {
+	db *bolt.DB
+}
+
+// price is a record as stored; the symbol is in the name of its bucket.
+type price struct {
+	Time     time.Time `json:"time"`
+	Price    float64   `json:"price"`
+	Kind     string    `json:"kind"`
+	Currency string    `json:"currency,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Note     string    `json:"note,omitempty"`
+}
+
+// Open opens the store at path, creating it and its directory if needed.
+// Only one process can have a store open at a time; Open waits up to a
+// few seconds for another one to close it.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{symbolsBucket, daysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Append stores r, replacing a price of the same symbol in the same quote at
+// the same time.
+func (s *Store) Append(r doctype.Record) error {
+	return s.AppendAll([]doctype.Record{r})
+}
+
+// AppendAll stores records in a single transaction: either all of them are
+// stored or none is.
+func (s *Store) AppendAll(records []doctype.Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, r := range records {
+			if err := put(tx, r); err != nil {
+				return fmt.Errorf("%s: %w", r.Symbol, err)
+			}
+		}
+		return nil
+	})
+}
+
+func put(tx *bolt.Tx, r doctype.Record) error {
+	if r.Symbol == "" {
+		return errors.New("record has no symbol")
+	}
+	value, err := json.Marshal(price{Time: r.Time, Price: r.Price, Kind: r.Kind, Currency: r.Currency, Provider: r.Provider, Note: r.Note})
+	if err != nil {
+		return err
+	}
+	key := []byte(r.Time.UTC().Format(keyLayout))
+	series := seriesName(r.Symbol, r.Currency)
+	prices, err := tx.Bucket(symbolsBucket).CreateBucketIfNotExists(series)
+	if err != nil {
+		return err
+	}
+	days := tx.Bucket(daysBucket)
+	// The replaced price may be dated in another time zone.
+	var prev price
+	if v := prices.Get(key); v != nil && json.Unmarshal(v, &prev) == nil {
+		if err := days.Delete(dayKey(prev.Time, series, key)); err != nil {
+			return err
+		}
+	}
+	if err := prices.Put(key, value); err != nil {
+		return err
+	}
+	return days.Put(dayKey(r.Time, series, key), nil)
+}
+
+// seriesName names the bucket of the prices of symbol in quote: the two
+// separated by a NUL byte.
+func seriesName(symbol, quote string) []byte {
+	return []byte(symbol + "\x00" + quote)
+}
+
+// dayKey is the index entry of a price: its day, series name and time key,
+// separated by NUL bytes.
+func dayKey(t time.Time, series, key []byte) []byte {
+	k := append([]byte(t.Format("2006-01-02")+"\x00"), series...)
+	return append(append(k, 0), key...)
+}
+
+// Symbols returns the symbols in the store in order.
+func (s *Store) Symbols() ([]string, error) {
+	var symbols []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(symbolsBucket).ForEachBucket(func(k []byte) error {
+			symbol, _, _ := bytes.Cut(k, []byte{0})
+			if n := len(symbols); n == 0 || symbols[n-1] != string(symbol) {
+				symbols = append(symbols, string(symbol))
+			}
+			return nil
+		})
+	})
+	return symbols, err
+}
+
+// Query selects prices. Zero values select everything.
+type Query struct {
+	Symbols []string
+	// From and To are the first and last day to include.
+	From, To time.Time
+}
+
+// Range calls fn with the prices q selects, ordered by day, symbol, quote and
+// time.
+// It stops at the first error fn returns and returns it.
+func (s *Store) Range(q Query, fn func(doctype.Record) error) error {
+	symbols := map[string]bool{}
+	for _, sym := range q.Symbols {
+		symbols[sym] = true
+	}
+	var from, to []byte
+	if !q.From.IsZero() {
+		from = []byte(q.From.Format("2006-01-02"))
+	}
+	if !q.To.IsZero() {
+		to = []byte(q.To.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		all := tx.Bucket(symbolsBucket)
+		c := tx.Bucket(daysBucket).Cursor()
+		k, _ := c.First()
+		if from != nil {
+			k, _ = c.Seek(from)
+		}
+		for ; k != nil; k, _ = c.Next() {
+			if to != nil && string(k) >= string(to) {
+				break
+			}
+			parts := bytes.SplitN(k, []byte{0}, 4)
+			if len(parts) != 4 {
+				continue
+			}
+			symbol, quote, key := string(parts[1]), string(parts[2]), parts[3]
+			if len(symbols) > 0 && !symbols[symbol] {
+				continue
+			}
+			prices := all.Bucket(seriesName(symbol, quote))
+			if prices == nil {
+				continue
+			}
+			value := prices.Get(key)
+			if value == nil {
+				continue
+			}
+			var p price
+			if err := json.Unmarshal(value, &p); err != nil {
+				return fmt.Errorf("%s at %s: %w", symbol, key, err)
+			}
+			r := doctype.Record{
+				Time: p.Time, Symbol: symbol, Price: p.Price, Kind: p.Kind,
+				Currency: p.Currency, Provider: p.Provider, Note: p.Note,
+			}
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
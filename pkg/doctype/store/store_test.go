@@ -0,0 +1,172 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+func open(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "history", "prices.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func at(day, hour int) time.Time {
+	return time.Date(2025, 9, day, hour, 0, 0, 0, time.UTC)
+}
+
+// list returns the prices q selects as "dayThour symbol price" strings.
+func list(t *testing.T, s *Store, q Query) string {
+	t.Helper()
+	var got []string
+	err := s.Range(q, func(r doctype.Record) error {
+		got = append(got, fmt.Sprintf("%s %s %g", r.Time.Format("02T15"), r.Symbol, r.Price))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(got, ", ")
+}
+
+func TestStore(t *testing.T) {
+	s := open(t)
+	records := []doctype.Record{
+		{Time: at(18, 20), Symbol: "AAPL", Price: 237.88, Kind: "commodity", Currency: "USD"},
+		{Time: at(17, 20), Symbol: "AAPL", Price: 238.15, Kind: "commodity", Currency: "USD"},
+		{Time: at(18, 16), Symbol: "SAP.DE", Price: 224.1, Kind: "commodity", Currency: "EUR", Provider: "stooq", Note: "stale: dated 2025-09-18"},
+		{Time: at(19, 8), Symbol: "EUR", Price: 1.18, Kind: "currency", Currency: "USD"},
+		// Replaces the first price.
+		{Time: at(18, 20), Symbol: "AAPL", Price: 237.9, Kind: "commodity", Currency: "USD"},
+	}
+	for _, r := range records {
+		if err := s.Append(r); err != nil {
+			t.Fatalf("Append(%+v): %v", r, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want string
+	}{
+		{"everything", Query{}, "17T20 AAPL 238.15, 18T20 AAPL 237.9, 18T16 SAP.DE 224.1, 19T08 EUR 1.18"},
+		{"by symbol", Query{Symbols: []string{"AAPL", "EUR"}}, "17T20 AAPL 238.15, 18T20 AAPL 237.9, 19T08 EUR 1.18"},
+		{"from", Query{From: at(18, 0)}, "18T20 AAPL 237.9, 18T16 SAP.DE 224.1, 19T08 EUR 1.18"},
+		{"to", Query{To: at(18, 0)}, "17T20 AAPL 238.15, 18T20 AAPL 237.9, 18T16 SAP.DE 224.1"},
+		{"one day", Query{From: at(18, 0), To: at(18, 0), Symbols: []string{"SAP.DE"}}, "18T16 SAP.DE 224.1"},
+	}
+	for _, tt := range tests {
+		if got := list(t, s, tt.q); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+
+	var got doctype.Record
+	s.Range(Query{Symbols: []string{"SAP.DE"}}, func(r doctype.Record) error {
+		got = r
+		return nil
+	})
+	if want := records[2]; !got.Time.Equal(want.Time) || got.Kind != want.Kind || got.Currency != want.Currency ||
+		got.Provider != want.Provider || got.Note != want.Note {
+		t.Errorf("record did not round-trip: %+v", got)
+	}
+
+	symbols, err := s.Symbols()
+	if err != nil || strings.Join(symbols, " ") != "AAPL EUR SAP.DE" {
+		t.Errorf("Symbols() = %v, %v", symbols, err)
+	}
+}
+
+func TestStore_CrossRates(t *testing.T) {
+	s := open(t)
+	records := []doctype.Record{
+		{Time: at(19, 8), Symbol: "EUR", Price: 1.178, Kind: "currency", Currency: "USD"},
+		{Time: at(19, 8), Symbol: "EUR", Price: 0.865, Kind: "currency", Currency: "GBP"},
+		// Replaces the price in dollars only.
+		{Time: at(19, 8), Symbol: "EUR", Price: 1.18, Kind: "currency", Currency: "USD"},
+	}
+	if err := s.AppendAll(records); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	s.Range(Query{}, func(r doctype.Record) error {
+		got = append(got, fmt.Sprintf("%s %g %s", r.Symbol, r.Price, r.Currency))
+		return nil
+	})
+	if strings.Join(got, ", ") != "EUR 0.865 GBP, EUR 1.18 USD" {
+		t.Errorf("unexpected prices: %v", got)
+	}
+	if symbols, err := s.Symbols(); err != nil || strings.Join(symbols, " ") != "EUR" {
+		t.Errorf("Symbols() = %v, %v", symbols, err)
+	}
+}
+
+func TestStore_ReplaceInAnotherZone(t *testing.T) {
+	s := open(t)
+	athens := time.FixedZone("EEST", 3*60*60)
+	if err := s.Append(doctype.Record{Time: at(18, 22), Symbol: "AAPL", Price: 1, Kind: "commodity"}); err != nil {
+		t.Fatal(err)
+	}
+	// The same instant is on the 19th in Athens.
+	if err := s.Append(doctype.Record{Time: at(18, 22).In(athens), Symbol: "AAPL", Price: 2, Kind: "commodity"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(t, s, Query{}); got != "19T01 AAPL 2" {
+		t.Errorf("expected the replaced price to leave the index, got %s", got)
+	}
+}
+
+func TestStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.bolt")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(doctype.Record{Time: at(18, 20), Symbol: "AAPL", Price: 237.88, Kind: "commodity"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := list(t, s, Query{}); got != "18T20 AAPL 237.88" {
+		t.Errorf("unexpected prices after reopening: %s", got)
+	}
+}
+
+func TestStore_Errors(t *testing.T) {
+	s := open(t)
+	if err := s.Append(doctype.Record{Time: at(18, 20), Price: 1, Kind: "commodity"}); err == nil {
+		t.Error("expected an error for a record without a symbol")
+	}
+	s.Append(doctype.Record{Time: at(18, 20), Symbol: "AAPL", Price: 1, Kind: "commodity"})
+	err := s.AppendAll([]doctype.Record{
+		{Time: at(19, 20), Symbol: "MSFT", Price: 1, Kind: "commodity"},
+		{Time: at(19, 20), Price: 1, Kind: "commodity"},
+	})
+	if err == nil {
+		t.Error("expected an error for a record without a symbol")
+	}
+	if got := list(t, s, Query{}); got != "18T20 AAPL 1" {
+		t.Errorf("expected AppendAll to store nothing after an error, got %s", got)
+	}
+
+	stop := errors.New("stop")
+	if err := s.Range(Query{}, func(doctype.Record) error { return stop }); err != stop {
+		t.Errorf("expected Range to return the error of fn, got %v", err)
+	}
+}
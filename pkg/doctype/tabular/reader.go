@@ -0,0 +1,248 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+// Mapping describes the layout of a CSV file of prices for Read.
+type Mapping struct {
+	// Columns maps the fields symbol, date, price, currency, provider and
+	// kind to the name of their column in the header line, or to its
+	// number counting from 1. Fields that are not mapped are read from the
+	// column of their own name, if there is one. Date and price must be
+	// found.
+	Columns map[string]string
+	// NoHeader is set when the first line already holds a price. Columns
+	// must then be given by number.
+	NoHeader bool
+	// Comma is the field delimiter, ',' by default.
+	Comma rune
+	// Symbol and Currency are used for rows without a symbol or currency,
+	// e.g. for a statement of a single security.
+	Symbol, Currency string
+	// DateFormats are the Go time layouts tried in order, by default
+	// DefaultDateFormats. Times without a zone are in Location, or local
+	// time when it is nil.
+	DateFormats []string
+	Location    *time.Location
+	// Decimal is the decimal separator of prices, '.' by default or ','.
+	// The other one, spaces and apostrophes group thousands and are
+	// ignored.
+	Decimal rune
+}
+
+// DefaultDateFormats are the date layouts of calais export and of most
+// tools: ISO dates and timestamps.
+var DefaultDateFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02", "2006/01/02 15:04:05", "2006/01/02"}
+
+// RowError reports a line that does not hold a valid price.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// Read reads the prices of a CSV file laid out as m describes. Rows that do
+// not hold a valid price are returned as *RowError values next to the
+// records that could be read. The error is set when the file cannot be read
+// at all, e.g. because a mapped column does not exist.
+func Read(r io.Reader, m Mapping) ([]doctype.Record, []error, error) {
+	cr := csv.NewReader(r)
+	if m.Comma != 0 {
+		cr.Comma = m.Comma
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var header []string
+	if !m.NoHeader {
+		var err error
+		if header, err = cr.Read(); err == io.EOF {
+			return nil, nil, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		// A byte order mark, as spreadsheets write it, is not part of the
+		// first name.
+		if len(header) > 0 {
+			header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		}
+	}
+	cols, err := m.columns(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		records []doctype.Record
+		errs    []error
+	)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return nil, nil, err
+			}
+			errs = append(errs, &RowError{Line: pe.Line, Err: pe.Err})
+			continue
+		}
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		rec, err := m.record(row, cols)
+		if err != nil {
+			errs = append(errs, &RowError{Line: line, Err: err})
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, errs, nil
+}
+
+// columns returns the index of each field found in the file.
+func (m Mapping) columns(header []string) (map[string]int, error) {
+	for field := range m.Columns {
+		if !contains(Columns, field) {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(Columns, ", "))
+		}
+	}
+	cols := map[string]int{}
+	for _, field := range Columns {
+		name, mapped := m.Columns[field]
+		if !mapped {
+			name = field
+		}
+		if n, err := strconv.Atoi(name); err == nil && n > 0 {
+			cols[field] = n - 1
+			continue
+		}
+		i := index(header, name)
+		switch {
+		case i >= 0:
+			cols[field] = i
+		case mapped && m.NoHeader:
+			return nil, fmt.Errorf("%s: column %q must be a number without a header line", field, name)
+		case mapped:
+			return nil, fmt.Errorf("%s: no column %q in the header line %q", field, name, strings.Join(header, string(m.comma())))
+		}
+	}
+	for _, field := range []string{"date", "price"} {
+		if _, ok := cols[field]; !ok {
+			return nil, fmt.Errorf("no %s column, map one with %s=COLUMN", field, field)
+		}
+	}
+	if _, ok := cols["symbol"]; !ok && m.Symbol == "" {
+		return nil, errors.New("no symbol column, map one with symbol=COLUMN or give the symbol")
+	}
+	return cols, nil
+}
+
+func (m Mapping) comma() rune {
+	if m.Comma == 0 {
+		return ','
+	}
+	return m.Comma
+}
+
+func (m Mapping) record(row []string, cols map[string]int) (doctype.Record, error) {
+	get := func(field string) string {
+		if i, ok := cols[field]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	rec := doctype.Record{
+		Symbol:   get("symbol"),
+		Currency: get("currency"),
+		Provider: get("provider"),
+		Kind:     get("kind"),
+	}
+	if rec.Symbol == "" {
+		rec.Symbol = m.Symbol
+	}
+	if rec.Symbol == "" {
+		return rec, errors.New("missing symbol")
+	}
+	if rec.Currency == "" {
+		rec.Currency = m.Currency
+	}
+	var err error
+	if rec.Time, err = m.parseDate(get("date")); err != nil {
+		return rec, err
+	}
+	if rec.Price, err = m.parsePrice(get("price")); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+func (m Mapping) parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("missing date")
+	}
+	formats, loc := m.DateFormats, m.Location
+	if len(formats) == 0 {
+		formats = DefaultDateFormats
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, layout := range formats {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected a date like %s", s, strings.Join(formats, " or "))
+}
+
+// parsePrice reads a positive price written with the decimal separator of
+// m, e.g. 1.234,56 with a decimal comma.
+func (m Mapping) parsePrice(s string) (float64, error) {
+	if s == "" {
+		return 0, errors.New("missing price")
+	}
+	decimal := '.'
+	if m.Decimal == ',' {
+		decimal = ','
+	}
+	price, err := providers.ParseNumber(s, decimal)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("invalid price %q, must be positive", s)
+	}
+	return price, nil
+}
+
+func contains(list []string, s string) bool {
+	return index(list, s) >= 0
+}
+
+func index(list []string, s string) int {
+	for i, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return i
+		}
+	}
+	return -1
+}
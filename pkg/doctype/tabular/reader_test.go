@@ -0,0 +1,167 @@
+package tabular
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+// rows formats records as "symbol date price currency" strings.
+func rows(records []doctype.Record) string {
+	var s []string
+	for _, r := range records {
+		s = append(s, fmt.Sprintf("%s %s %g %s", r.Symbol, r.Time.Format("2006-01-02T15:04Z07:00"), r.Price, r.Currency))
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestRead(t *testing.T) {
+	athens, err := time.LoadLocation("Europe/Athens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+		m    Mapping
+		want string
+	}{
+		{
+			name: "calais export",
+			data: "symbol,date,price,currency,provider,kind\n" +
+				"AAPL,2025-09-18T00:00:00Z,237.88,USD,stooq,commodity\n" +
+				"EUR,2025-09-18,1.1812,USD,fixer,currency\n",
+			m:    Mapping{Location: time.UTC},
+			want: "AAPL 2025-09-18T00:00Z 237.88 USD, EUR 2025-09-18T00:00Z 1.1812 USD",
+		},
+		{
+			name: "pricehist",
+			data: "date,base,quote,amount,source,type\n2025-09-17,AAPL,USD,238.15,yahoo,close\n",
+			m:    Mapping{Columns: map[string]string{"symbol": "base", "price": "amount", "currency": "quote"}, Location: time.UTC},
+			want: "AAPL 2025-09-17T00:00Z 238.15 USD",
+		},
+		{
+			name: "broker statement",
+			data: "\ufeffDatum;Schlusskurs;Volumen\n18.09.2025 17:30;1.234,56;100\n17.09.2025 17:30;1 230,5;80\n",
+			m: Mapping{
+				Columns:     map[string]string{"date": "Datum", "price": "schlusskurs"},
+				Comma:       ';',
+				Decimal:     ',',
+				Symbol:      "SAP.DE",
+				Currency:    "EUR",
+				DateFormats: []string{"02.01.2006 15:04"},
+				Location:    athens,
+			},
+			want: "SAP.DE 2025-09-18T17:30+03:00 1234.56 EUR, SAP.DE 2025-09-17T17:30+03:00 1230.5 EUR",
+		},
+		{
+			name: "no header",
+			data: "VOD.L,2025/09/18,72.5,GBX\n\n",
+			m:    Mapping{NoHeader: true, Columns: map[string]string{"symbol": "1", "date": "2", "price": "3", "currency": "4"}, Location: time.UTC},
+			want: "VOD.L 2025-09-18T00:00Z 72.5 GBX",
+		},
+	}
+	for _, tt := range tests {
+		records, errs, err := Read(strings.NewReader(tt.data), tt.m)
+		if err != nil || len(errs) > 0 {
+			t.Errorf("%s: Read() failed: %v %v", tt.name, err, errs)
+			continue
+		}
+		if got := rows(records); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRead_InvalidRows(t *testing.T) {
+	data := "symbol,date,price\n" +
+		"AAPL,2025-09-18,237.88\n" +
+		",2025-09-18,1\n" +
+		"AAPL,18/09/2025,1\n" +
+		"AAPL,2025-09-17,-3\n" +
+		"AAPL,2025-09-16,n/a\n" +
+		"AAPL,2025-09-15\n"
+	records, errs, err := Read(strings.NewReader(data), Mapping{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Symbol != "AAPL" {
+		t.Errorf("unexpected records %+v", records)
+	}
+	want := []string{
+		"line 3: missing symbol",
+		`line 4: invalid date "18/09/2025"`,
+		`line 5: invalid price "-3", must be positive`,
+		`line 6: invalid price "n/a"`,
+		"line 7: missing price",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(errs[i].Error(), w) {
+			t.Errorf("error %d: got %q, want %q", i, errs[i], w)
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		s       string
+		decimal rune
+		want    float64
+	}{
+		{"237.88", 0, 237.88},
+		{"1,234.5", 0, 1234.5},
+		{"1,234,567", 0, 1234567},
+		{"1 234.5", 0, 1234.5},
+		{"1'234.5", 0, 1234.5},
+		{"1.234,5", ',', 1234.5},
+		{"1\u202f234,5", ',', 1234.5},
+		{"150,75", ',', 150.75},
+		// A decimal comma read with the default decimal point.
+		{"150,75", 0, 0},
+		{"1,5", 0, 0},
+		{"1,2345.6", 0, 0},
+		{"1234,567", 0, 0},
+		{",123", 0, 0},
+		{"1,,234", 0, 0},
+		{"1.234.5", 0, 0},
+		{"1.234,5", 0, 0},
+		{"150.75", ',', 0},
+	}
+	for _, tt := range tests {
+		got, err := Mapping{Decimal: tt.decimal}.parsePrice(tt.s)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("parsePrice(%q) with decimal %q = %g, expected an error", tt.s, tt.decimal, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parsePrice(%q) with decimal %q = %g, %v, want %g", tt.s, tt.decimal, got, err, tt.want)
+		}
+	}
+}
+
+func TestRead_Mapping(t *testing.T) {
+	tests := []struct {
+		m    Mapping
+		want string
+	}{
+		{Mapping{Columns: map[string]string{"volume": "Volumen"}}, `unknown field "volume"`},
+		{Mapping{Columns: map[string]string{"price": "Close"}}, `price: no column "Close"`},
+		{Mapping{Columns: map[string]string{"price": "Kurs"}}, "no date column"},
+		{Mapping{Columns: map[string]string{"symbol": "Ticker"}, NoHeader: true}, "must be a number"},
+		{Mapping{Columns: map[string]string{"date": "Datum", "price": "Kurs"}}, "no symbol column"},
+	}
+	for _, tt := range tests {
+		_, _, err := Read(strings.NewReader("Datum,Kurs\n2025-09-18,1\n"), tt.m)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: expected an error containing %q, got %v", tt.m.Columns, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package tabular writes prices as rows for analysis tools such as pandas:
+// CSV with a header line, or JSON Lines with one object per price. Both
+// have the fields listed in Columns. Read reads prices from CSV files of
+// other layouts, such as broker statements.
+package tabular
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+// Columns are the fields of a price, in the order CSV writes them.
+var Columns = []string{"symbol", "date", "price", "currency", "provider", "kind"}
+
+// Option configures a writer.
+type Option func(*options)
+
+type options struct {
+	loc   *time.Location
+	dates doctype.Dates
+}
+
+// WithTimes writes the time of each price in loc, as described by
+// doctype.In, and with or without the time of day following dates. By
+// default times are RFC 3339 timestamps in their own time zone.
+func WithTimes(loc *time.Location, dates doctype.Dates) Option {
+	return func(o *options) { o.loc, o.dates = loc, dates }
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) date(t time.Time) string {
+	layout := time.RFC3339
+	if o.dates.DateOnly(t) {
+		layout = "2006-01-02"
+	}
+	return doctype.In(t, o.loc).Format(layout)
+}
+
+// CSVWriter writes prices as CSV. The header is written with the first
+// price.
+type CSVWriter struct {
+	w      *csv.Writer
+	opts   options
+	header bool
+}
+
+func NewCSV(w io.Writer, opts ...Option) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w), opts: newOptions(opts)}
+}
+
+func (c *CSVWriter) Append(r doctype.Record) error {
+	if !c.header {
+		if err := c.w.Write(Columns); err != nil {
+			return err
+		}
+		c.header = true
+	}
+	price := strconv.FormatFloat(r.Price, 'f', -1, 64)
+	if err := c.w.Write([]string{r.Symbol, c.opts.date(r.Time), price, r.Currency, r.Provider, r.Kind}); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// JSONLWriter writes prices as JSON Lines.
+type JSONLWriter struct {
+	enc  *json.Encoder
+	opts options
+}
+
+func NewJSONL(w io.Writer, opts ...Option) *JSONLWriter {
+	return &JSONLWriter{enc: json.NewEncoder(w), opts: newOptions(opts)}
+}
+
+// row is a price as JSONLWriter writes it.
+type row struct {
+	Symbol   string  `json:"symbol"`
+	Date     string  `json:"date"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+	Provider string  `json:"provider"`
+	Kind     string  `json:"kind"`
+}
+
+func (j *JSONLWriter) Append(r doctype.Record) error {
+	return j.enc.Encode(row{
+		Symbol:   r.Symbol,
+		Date:     j.opts.date(r.Time),
+		Price:    r.Price,
+		Currency: r.Currency,
+		Provider: r.Provider,
+		Kind:     r.Kind,
+	})
+}
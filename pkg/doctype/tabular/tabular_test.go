@@ -0,0 +1,67 @@
+package tabular
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/doctype"
+)
+
+var records = []doctype.Record{
+	{Time: time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC), Symbol: "AAPL", Price: 237.88, Kind: "commodity", Currency: "USD", Provider: "stooq"},
+	{Time: time.Date(2025, 9, 18, 8, 29, 7, 0, time.FixedZone("EEST", 3*60*60)), Symbol: "EUR", Price: 1.1812, Kind: "currency", Currency: "USD", Provider: "fixer"},
+	{Time: time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC), Symbol: "GOLD, 1g", Price: 98.5, Kind: "commodity", Provider: "derived"},
+}
+
+func write(t *testing.T, w doctype.PriceWriter) {
+	t.Helper()
+	for _, r := range records {
+		if err := w.Append(r); err != nil {
+			t.Fatalf("Append(%+v): %v", r, err)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var b strings.Builder
+	write(t, NewCSV(&b))
+	want := `symbol,date,price,currency,provider,kind
+AAPL,2025-09-18T00:00:00Z,237.88,USD,stooq,commodity
+EUR,2025-09-18T08:29:07+03:00,1.1812,USD,fixer,currency
+"GOLD, 1g",2025-09-18T00:00:00Z,98.5,,derived,commodity
+`
+	if got := b.String(); got != want {
+		t.Errorf("unexpected CSV:\n%s\nwant:\n%s", got, want)
+	}
+
+	// End-of-day prices keep their date in another time zone.
+	b.Reset()
+	write(t, NewCSV(&b, WithTimes(time.FixedZone("EDT", -4*60*60), doctype.Timestamps)))
+	want = `symbol,date,price,currency,provider,kind
+AAPL,2025-09-18T00:00:00-04:00,237.88,USD,stooq,commodity
+EUR,2025-09-18T01:29:07-04:00,1.1812,USD,fixer,currency
+"GOLD, 1g",2025-09-18T00:00:00-04:00,98.5,,derived,commodity
+`
+	if got := b.String(); got != want {
+		t.Errorf("unexpected CSV in EDT:\n%s\nwant:\n%s", got, want)
+	}
+
+	b.Reset()
+	NewCSV(&b)
+	if b.Len() != 0 {
+		t.Errorf("expected no header without prices, got %q", b.String())
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	var b strings.Builder
+	write(t, NewJSONL(&b, WithTimes(nil, doctype.DatesAuto)))
+	want := `{"symbol":"AAPL","date":"2025-09-18","price":237.88,"currency":"USD","provider":"stooq","kind":"commodity"}
+{"symbol":"EUR","date":"2025-09-18T08:29:07+03:00","price":1.1812,"currency":"USD","provider":"fixer","kind":"currency"}
+{"symbol":"GOLD, 1g","date":"2025-09-18","price":98.5,"currency":"","provider":"derived","kind":"commodity"}
+`
+	if got := b.String(); got != want {
+		t.Errorf("unexpected JSON Lines:\n%s\nwant:\n%s", got, want)
+	}
+}
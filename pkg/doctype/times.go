@@ -0,0 +1,42 @@
+package doctype
+
+import "time"
+
+// Dates is the policy for writing the time of a price.
+type Dates string
+
+const (
+	// Timestamps writes the date and time of day of every price.
+	Timestamps Dates = "timestamp"
+	// DatesOnly writes only the date of every price.
+	DatesOnly Dates = "date"
+	// DatesAuto writes only the date of prices dated by day, see IsDate,
+	// and the date and time of day of others.
+	DatesAuto Dates = "auto"
+)
+
+// IsDate reports whether t stands for a day rather than an instant.
+// Providers date end-of-day prices at midnight, in UTC or in the time zone of
+// the exchange.
+func IsDate(t time.Time) bool {
+	h, m, s := t.Clock()
+	return h == 0 && m == 0 && s == 0 && t.Nanosecond() == 0
+}
+
+// In returns t in loc. A date keeps its day: moving midnight into another
+// time zone would move it to the day before or after.
+func In(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		return t
+	}
+	if IsDate(t) {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	return t.In(loc)
+}
+
+// DateOnly reports whether a price dated t is written without its time of
+// day under the policy.
+func (d Dates) DateOnly(t time.Time) bool {
+	return d == DatesOnly || d == DatesAuto && IsDate(t)
+}
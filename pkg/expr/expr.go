@@ -0,0 +1,273 @@
+// Package expr evaluates small arithmetic expressions over named prices, such
+// as "XAU / 31.1035". It supports the four basic operators, parentheses and
+// unary minus. Names start with a letter or underscore and may contain
+// letters, digits, underscores and dots; other names, like "7203.T" or the
+// currency pair "EUR/USD", can be written in double quotes.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Expr is a parsed expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+type node interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type num float64
+
+type ref string
+
+type neg struct{ x node }
+
+type binary struct {
+	op   byte
+	l, r node
+}
+
+func (n num) eval(map[string]float64) (float64, error) { return float64(n), nil }
+
+func (n ref) eval(vars map[string]float64) (float64, error) {
+	v, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("unknown price %q", string(n))
+	}
+	return v, nil
+}
+
+func (n neg) eval(vars map[string]float64) (float64, error) {
+	v, err := n.x.eval(vars)
+	return -v, err
+}
+
+func (n binary) eval(vars map[string]float64) (float64, error) {
+	l, err := n.l.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.r.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	default:
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	}
+}
+
+// Parse parses s.
+func Parse(s string) (*Expr, error) {
+	p := &parser{src: s}
+	p.next()
+	root, err := p.sum()
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", s, err)
+	}
+	if p.tok != eof {
+		return nil, fmt.Errorf("expression %q: unexpected %q at offset %d", s, p.text, p.start)
+	}
+	return &Expr{src: s, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string { return e.src }
+
+// Eval evaluates the expression with the given prices.
+func (e *Expr) Eval(vars map[string]float64) (float64, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return 0, fmt.Errorf("expression %q: %w", e.src, err)
+	}
+	return v, nil
+}
+
+// Vars returns the names referenced by the expression in order of first
+// appearance.
+func (e *Expr) Vars() []string {
+	var names []string
+	seen := map[string]bool{}
+	var walk func(node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case ref:
+			if !seen[string(n)] {
+				seen[string(n)] = true
+				names = append(names, string(n))
+			}
+		case neg:
+			walk(n.x)
+		case binary:
+			walk(n.l)
+			walk(n.r)
+		}
+	}
+	walk(e.root)
+	return names
+}
+
+type token int
+
+const (
+	eof token = iota
+	number
+	name
+	op
+	invalid
+)
+
+type parser struct {
+	src   string
+	pos   int
+	start int
+	tok   token
+	text  string
+}
+
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(c) {
+			break
+		}
+		p.pos += size
+	}
+	p.start = p.pos
+	if p.pos >= len(p.src) {
+		p.tok, p.text = eof, ""
+		return
+	}
+
+	c := rune(p.src[p.pos])
+	switch {
+	case strings.ContainsRune("+-*/()", c):
+		p.pos++
+		p.tok = op
+	case c == '"':
+		end := strings.IndexByte(p.src[p.pos+1:], '"')
+		if end < 0 {
+			p.pos = len(p.src)
+			p.tok = invalid
+			break
+		}
+		p.tok = name
+		p.text = p.src[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.src) && (unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = number
+	case unicode.IsLetter(c) || c == '_':
+		for p.pos < len(p.src) && isNameChar(rune(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = name
+	default:
+		p.pos++
+		p.tok = invalid
+	}
+	p.text = p.src[p.start:p.pos]
+}
+
+func isNameChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.'
+}
+
+// sum = product { ("+" | "-") product }
+func (p *parser) sum() (node, error) {
+	l, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == op && (p.text == "+" || p.text == "-") {
+		o := p.text[0]
+		p.next()
+		r, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: o, l: l, r: r}
+	}
+	return l, nil
+}
+
+// product = unary { ("*" | "/") unary }
+func (p *parser) product() (node, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == op && (p.text == "*" || p.text == "/") {
+		o := p.text[0]
+		p.next()
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: o, l: l, r: r}
+	}
+	return l, nil
+}
+
+// unary = "-" unary | primary
+func (p *parser) unary() (node, error) {
+	if p.tok == op && p.text == "-" {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return neg{x: x}, nil
+	}
+	return p.primary()
+}
+
+// primary = number | name | "(" sum ")"
+func (p *parser) primary() (node, error) {
+	switch {
+	case p.tok == number:
+		v, err := strconv.ParseFloat(p.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.text)
+		}
+		p.next()
+		return num(v), nil
+	case p.tok == name:
+		n := ref(p.text)
+		p.next()
+		return n, nil
+	case p.tok == op && p.text == "(":
+		p.next()
+		x, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != op || p.text != ")" {
+			return nil, fmt.Errorf("missing ) at offset %d", p.start)
+		}
+		p.next()
+		return x, nil
+	case p.tok == eof:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", p.text, p.start)
+}
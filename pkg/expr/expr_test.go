@@ -0,0 +1,67 @@
+package expr
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{
+		"XAU":     3000,
+		"EUR":     1.2,
+		"SXR8.DE": 600,
+		"7203.T":  2500,
+		"ZERO":    0,
+		"EUR/GBP": 0.86,
+	}
+	tests := []struct {
+		expr    string
+		want    float64
+		wantErr bool
+	}{
+		{expr: "XAU / 31.1035", want: 3000 / 31.1035},
+		{expr: "1 + 2 * 3", want: 7},
+		{expr: "(1 + 2) * 3", want: 9},
+		{expr: "-EUR + 2", want: 0.8},
+		{expr: "10 - 4 - 3", want: 3},
+		{expr: "SXR8.DE / EUR", want: 500},
+		{expr: `"7203.T" * 2`, want: 5000},
+		{expr: `100 * "EUR/GBP"`, want: 86},
+		{expr: "XAU\t/\n(1 +\u00a01)", want: 1500},
+		{expr: "MISSING * 2", wantErr: true},
+		{expr: "XAU / ZERO", wantErr: true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		got, err := e.Eval(vars)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Eval(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Eval(%q) = %v; want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, s := range []string{"", "1 +", "(1 + 2", "1 2", "XAU % 2", `"XAU`, "1..2"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): expected error, got nil", s)
+		}
+	}
+}
+
+func TestVars(t *testing.T) {
+	e, err := Parse(`(XAU + XAG) / XAU * "7203.T"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.Vars(), []string{"XAU", "XAG", "7203.T"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Vars() = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+// Package httpcache keeps the responses of provider HTTP requests on disk, so
+// that running calais again, e.g. after fixing a typo in the configuration,
+// does not spend API quota on requests it already made.
+//
+// Responses are keyed by the request and the day it is made on, so a cached
+// price is never carried over into the next trading day, and are used for
+// a limited time. Only successful GET responses are cached, and of those
+// only the ones that pass the check the request carries, see
+// providers.WithResponseCheck. Files are named by a hash of the request, so
+// API keys sent in URLs are not written to disk.
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+// DefaultTTL is how long responses are used by default.
+const DefaultTTL = time.Hour
+
+// DefaultDir returns $XDG_CACHE_HOME/calais/http, or ~/.cache/calais/http.
+func DefaultDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(os.TempDir(), "calais-http")
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "calais", "http")
+}
+
+// Client is a providers.HTTPDoer that serves repeated requests from the
+// cache and passes others on.
+type Client struct {
+	doer providers.HTTPDoer
+	dir  string
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu     sync.Mutex
+	pruned time.Time // when expired responses were last removed
+}
+
+// New returns a client caching the responses of doer in dir for ttl.
+func New(doer providers.HTTPDoer, dir string, ttl time.Duration) *Client {
+	return &Client{doer: doer, dir: dir, ttl: ttl, now: time.Now}
+}
+
+// Do returns the cached response to req if there is a fresh one, or else
+// sends req and caches a successful response that passes the check of req.
+// Failing to use the cache is not an error; the request is then sent as
+// usual.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.doer.Do(req)
+	}
+	c.prune()
+
+	path := filepath.Join(c.dir, c.key(req)+".http")
+	if resp, ok := c.load(path, req); ok {
+		return resp, nil
+	}
+	resp, err := c.doer.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	if check := providers.ResponseCheck(req); check != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if check(body) != nil {
+			return resp, nil
+		}
+	}
+	// DumpResponse reads the body and leaves a copy in its place.
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+	c.store(path, data)
+	return resp, nil
+}
+
+// key hashes the request and the day it is made on.
+func (c *Client) key(req *http.Request) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.String()+"\n")
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range req.Header[name] {
+			io.WriteString(h, name+": "+v+"\n")
+		}
+	}
+	io.WriteString(h, c.now().Format("2006-01-02"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Client) load(path string, req *http.Request) (*http.Response, bool) {
+	fi, err := os.Stat(path)
+	if err != nil || c.now().Sub(fi.ModTime()) > c.ttl {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+// store writes a response atomically. Errors are ignored: the response was
+// received and the next run will simply send the request again.
+func (c *Client) store(path string, data []byte) {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// prune removes the expired responses at most once per ttl, so that the
+// cache does not grow while calais serve runs for days.
+func (c *Client) prune() {
+	c.mu.Lock()
+	now := c.now()
+	due := c.pruned.IsZero() || now.Sub(c.pruned) >= c.ttl
+	if due {
+		c.pruned = now
+	}
+	c.mu.Unlock()
+	if due {
+		c.removeExpired()
+	}
+}
+
+// removeExpired deletes the responses that can no longer be used.
+func (c *Client) removeExpired() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err == nil && filepath.Ext(e.Name()) == ".http" && c.now().Sub(fi.ModTime()) > c.ttl {
+			os.Remove(filepath.Join(c.dir, e.Name()))
+		}
+	}
+}
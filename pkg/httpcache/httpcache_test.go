@@ -0,0 +1,173 @@
+package httpcache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+// countingDoer answers every request with the number of requests so far.
+type countingDoer struct {
+	calls  int
+	status int
+}
+
+func (d *countingDoer) Do(req *http.Request) (*http.Response, error) {
+	d.calls++
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	if d.status != 0 {
+		rec.WriteHeader(d.status)
+	}
+	fmt.Fprintf(rec, `{"calls": %d}`, d.calls)
+	return rec.Result(), nil
+}
+
+func get(t *testing.T, c *Client, url string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Header.Get("Content-Type") + " " + string(body)
+}
+
+func TestClient_Do(t *testing.T) {
+	dir := t.TempDir()
+	doer := &countingDoer{}
+	c := New(doer, dir, time.Hour)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	const url = "https://api.example.com/eod?symbols=AAPL&access_key=secret"
+	if got := get(t, c, url); got != `application/json {"calls": 1}` {
+		t.Errorf("unexpected first response %q", got)
+	}
+	if got := get(t, c, url); got != `application/json {"calls": 1}` || doer.calls != 1 {
+		t.Errorf("expected a cached response, got %q after %d calls", got, doer.calls)
+	}
+	if got := get(t, c, url+"&x=1"); got != `application/json {"calls": 2}` {
+		t.Errorf("expected another request to be sent, got %q", got)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.http"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected 2 cached responses, got %v, %v", files, err)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("cached response %s contains the API key", f)
+		}
+	}
+
+	// A new day does not use the responses of the day before, and the
+	// client removes them once they expired.
+	now = now.AddDate(0, 0, 1)
+	if get(t, c, url); doer.calls != 3 {
+		t.Errorf("expected a request on the next day, got %d calls", doer.calls)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.http")); len(files) != 1 {
+		t.Errorf("expected the expired responses to be removed, got %v", files)
+	}
+}
+
+func TestClient_Expiry(t *testing.T) {
+	dir := t.TempDir()
+	doer := &countingDoer{}
+	c := New(doer, dir, time.Minute)
+	get(t, c, "https://api.example.com/a")
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.http"))
+	if len(files) != 1 {
+		t.Fatalf("expected one cached response, got %v", files)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(files[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	if get(t, c, "https://api.example.com/a"); doer.calls != 2 {
+		t.Errorf("expected an expired response to be fetched again, got %d calls", doer.calls)
+	}
+
+	// A new client removes expired responses.
+	if err := os.Chtimes(files[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	get(t, New(doer, dir, time.Minute), "https://api.example.com/b")
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("expected the expired response to be removed, got %v", err)
+	}
+}
+
+func TestClient_DoesNotCacheFailures(t *testing.T) {
+	doer := &countingDoer{status: http.StatusTooManyRequests}
+	c := New(doer, t.TempDir(), time.Hour)
+	get(t, c, "https://api.example.com/a")
+	get(t, c, "https://api.example.com/a")
+	if doer.calls != 2 {
+		t.Errorf("expected failed responses not to be cached, got %d calls", doer.calls)
+	}
+}
+
+func TestClient_ResponseCheck(t *testing.T) {
+	doer := &countingDoer{}
+	c := New(doer, t.TempDir(), time.Hour)
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.com/a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = providers.WithResponseCheck(req, func(body []byte) error {
+		if strings.Contains(string(body), `"calls": 1}`) {
+			return errors.New("quota exceeded")
+		}
+		return nil
+	})
+	for i, want := range []string{`{"calls": 1}`, `{"calls": 2}`, `{"calls": 2}`} {
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Errorf("request %d: got %s, want %s", i+1, body, want)
+		}
+	}
+	if doer.calls != 2 {
+		t.Errorf("expected the rejected response not to be cached, got %d calls", doer.calls)
+	}
+}
+
+func TestDefaultDir(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/cache")
+	if got := DefaultDir(); got != "/cache/calais/http" {
+		t.Errorf("unexpected dir %q", got)
+	}
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("HOME", "/home/me")
+	if got := DefaultDir(); got != "/home/me/.cache/calais/http" {
+		t.Errorf("unexpected dir %q", got)
+	}
+}
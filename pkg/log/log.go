@@ -58,6 +58,13 @@ func (l *Logger) Debug(message string, keyvals ...interface{}) {
 	l.logger.WithFields(toMap(keyvals...)).Debug(message)
 }
 
+// Warn logs at warning log level, for something done in a way that needs
+// attention. For each key, a value should also be provided. If a value is not
+// provided, the key will be ignored.
+func (l *Logger) Warn(message string, keyvals ...interface{}) {
+	l.logger.WithFields(toMap(keyvals...)).Warn(message)
+}
+
 // Error logs at error log level. For each key, a value should also be provided. If
 // a value is not provided, the key will be ignored.
 func (l *Logger) Error(message string, keyvals ...interface{}) {
@@ -22,6 +22,12 @@ func TestNew(t *testing.T) {
 	if got, want := buf.String(), "level=info msg=bar"; !strings.Contains(got, want) {
 		t.Errorf("expected logging message %q to contain %q", got, want)
 	}
+	buf.Reset()
+
+	logger.Warn("baz")
+	if got, want := buf.String(), "level=warning msg=baz"; !strings.Contains(got, want) {
+		t.Errorf("expected logging message %q to contain %q", got, want)
+	}
 }
 
 func TestToMap(t *testing.T) {
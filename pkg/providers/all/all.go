@@ -0,0 +1,13 @@
+// Package all registers every provider shipped with calais. Import it for its
+// side effects; new providers only need to be added here.
+package all
+
+import (
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/exec"
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/fixer"
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/httpjson"
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/manual"
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/marketstack"
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/scrape"
+	_ "git.sr.ht/~atmosx/calais/pkg/providers/stooq"
+)
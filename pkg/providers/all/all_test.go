@@ -0,0 +1,15 @@
+package all
+
+import (
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+func TestRegistered(t *testing.T) {
+	want := []string{"exec", "fixer", "httpjson", "manual", "marketstack", "scrape", "stooq"}
+	if got := providers.Types(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Types() = %v; want %v", got, want)
+	}
+}
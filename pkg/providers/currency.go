@@ -0,0 +1,32 @@
+package providers
+
+// minorUnits are the quote currencies some exchanges use for prices in a
+// fraction of a currency, by the codes providers report them with. London
+// quotes in pence, Johannesburg in cents and Tel Aviv in agorot.
+var minorUnits = map[string]struct {
+	major string
+	per   float64
+}{
+	"GBX": {"GBP", 100},
+	"GBp": {"GBP", 100},
+	"ZAc": {"ZAR", 100},
+	"ZAX": {"ZAR", 100},
+	"ILA": {"ILS", 100},
+	"ILa": {"ILS", 100},
+}
+
+// IsMinorUnit reports whether currency is a known minor-unit quote currency
+// such as GBX. The codes are case sensitive: GBp is pence but GBP pounds.
+func IsMinorUnit(currency string) bool {
+	_, ok := minorUnits[currency]
+	return ok
+}
+
+// MajorUnit converts a price quoted in a minor unit such as GBX into its
+// major currency. Other prices are returned as they are.
+func MajorUnit(price float64, currency string) (float64, string) {
+	if m, ok := minorUnits[currency]; ok {
+		return price / m.per, m.major
+	}
+	return price, currency
+}
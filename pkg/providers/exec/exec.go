@@ -0,0 +1,166 @@
+// Package exec implements a provider that delegates fetching to an external
+// command. It is meant for in-house scrapers of sources no API covers.
+//
+// The command is run once per symbol with the symbol, the requested date
+// (YYYY-MM-DD) and the configured currency appended to its arguments. The same
+// values are exported as CALAIS_SYMBOL, CALAIS_DATE and CALAIS_CURRENCY. The
+// command must print either a JSON object:
+//
+//	{"date": "2025-09-18", "price": 12.34, "currency": "EUR"}
+//
+// or a single line of the form date,price[,currency] on stdout.
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+// DefaultTimeout bounds a command run when no timeout is configured.
+const DefaultTimeout = 30 * time.Second
+
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "2006/01/02"}
+
+type Client struct {
+	command  []string
+	currency string
+	timeout  time.Duration
+	logger   *log.Logger
+	now      func() time.Time
+}
+
+// Options configure an exec provider instance.
+type Options struct {
+	Command  []string      `yaml:"command"`
+	Currency string        `yaml:"currency"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+type output struct {
+	Date     string  `json:"date"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+}
+
+func init() {
+	providers.Register("exec",
+		func() interface{} { return &Options{Timeout: DefaultTimeout} },
+		func(inst providers.Instance, options interface{}) (interface{}, error) {
+			o := options.(*Options)
+			if len(o.Command) == 0 {
+				return nil, fmt.Errorf("missing command")
+			}
+			return New(o.Command, o.Currency, o.Timeout, inst.Logger), nil
+		})
+}
+
+func New(command []string, currency string, timeout time.Duration, logger *log.Logger) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{
+		command:  command,
+		currency: currency,
+		timeout:  timeout,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+func (c *Client) FetchStock(symbol string) (*providers.StockData, error) {
+	if len(c.command) == 0 {
+		return nil, fmt.Errorf("no command configured")
+	}
+	date := c.now().Format("2006-01-02")
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	args := append(append([]string{}, c.command[1:]...), symbol, date, c.currency)
+	cmd := osexec.CommandContext(ctx, c.command[0], args...)
+	cmd.Env = append(os.Environ(),
+		"CALAIS_SYMBOL="+symbol,
+		"CALAIS_DATE="+date,
+		"CALAIS_CURRENCY="+c.currency,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait on grandchildren that keep stdout open after a timeout.
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("command for %s timed out after %s", symbol, c.timeout)
+		}
+		c.logger.Error("Command failed", "symbol", symbol, "stderr", strings.TrimSpace(stderr.String()), "error", err)
+		return nil, fmt.Errorf("command for %s failed: %w", symbol, err)
+	}
+
+	out, err := parseOutput(stdout.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid output for %s: %w", symbol, err)
+	}
+	t, err := parseDate(out.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output for %s: %w", symbol, err)
+	}
+	currency := out.Currency
+	if currency == "" {
+		currency = c.currency
+	}
+	return &providers.StockData{
+		Symbol:   symbol,
+		Date:     t,
+		Close:    out.Price,
+		Currency: currency,
+	}, nil
+}
+
+func parseOutput(s string) (*output, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty output")
+	}
+	var out output
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &out); err != nil {
+			return nil, err
+		}
+		return &out, nil
+	}
+
+	fields := strings.Split(strings.SplitN(s, "\n", 2)[0], ",")
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("expected date,price[,currency], got %q", s)
+	}
+	price, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price %q", fields[1])
+	}
+	out.Date = strings.TrimSpace(fields[0])
+	out.Price = price
+	if len(fields) == 3 {
+		out.Currency = strings.TrimSpace(fields[2])
+	}
+	return &out, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
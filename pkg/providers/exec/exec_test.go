@@ -0,0 +1,130 @@
+package exec
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+func newTestClient(script string, timeout time.Duration) *Client {
+	logger := log.New(io.Discard, "Error")
+	c := New([]string{"sh", "-c", script, "sh"}, "EUR", timeout, logger)
+	c.now = func() time.Time { return time.Date(2025, 9, 18, 10, 0, 0, 0, time.UTC) }
+	return c
+}
+
+func TestFetchStock(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		expectError bool
+		price       float64
+		currency    string
+	}{
+		{
+			name:     "csv line",
+			script:   `echo "2025-09-18,12.34"`,
+			price:    12.34,
+			currency: "EUR",
+		},
+		{
+			name:     "csv line with currency",
+			script:   `echo "2025-09-18,12.34,USD"`,
+			price:    12.34,
+			currency: "USD",
+		},
+		{
+			name:     "json",
+			script:   `echo '{"date":"2025-09-18","price":7.5,"currency":"GBP"}'`,
+			price:    7.5,
+			currency: "GBP",
+		},
+		{
+			name:     "arguments",
+			script:   `echo "$2,1,$3"; test "$1" = FUND1`,
+			price:    1,
+			currency: "EUR",
+		},
+		{
+			name:     "environment",
+			script:   `echo "$CALAIS_DATE,2,$CALAIS_CURRENCY"; test "$CALAIS_SYMBOL" = FUND1`,
+			price:    2,
+			currency: "EUR",
+		},
+		{
+			name:        "non-zero exit",
+			script:      `echo "2025-09-18,1"; exit 1`,
+			expectError: true,
+		},
+		{
+			name:        "empty output",
+			script:      `true`,
+			expectError: true,
+		},
+		{
+			name:        "bad price",
+			script:      `echo "2025-09-18,abc"`,
+			expectError: true,
+		},
+		{
+			name:        "bad date",
+			script:      `echo "yesterday,1"`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd, err := newTestClient(tt.script, time.Second).FetchStock("FUND1")
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("did not expect an error: %v", err)
+			}
+			if sd.Symbol != "FUND1" || sd.Close != tt.price || sd.Currency != tt.currency {
+				t.Errorf("unexpected data: %+v", sd)
+			}
+			if sd.Date.Year() != 2025 || sd.Date.Month() != time.September || sd.Date.Day() != 18 {
+				t.Errorf("date mismatch: %v", sd.Date)
+			}
+		})
+	}
+}
+
+func TestFetchStock_Timeout(t *testing.T) {
+	_, err := newTestClient(`sleep 5`, 50*time.Millisecond).FetchStock("FUND1")
+	if err == nil {
+		t.Fatal("expected timeout error, got nil")
+	}
+}
+
+func TestNew(t *testing.T) {
+	c := New([]string{"true"}, "EUR", 0, log.New(io.Discard, "Error"))
+	if c.timeout != DefaultTimeout {
+		t.Errorf("expected default timeout, got %s", c.timeout)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	inst := providers.Instance{Name: "funds", Logger: log.New(io.Discard, "Error")}
+	if _, err := providers.New("exec", inst, nil); err == nil {
+		t.Error("expected error for missing command, got nil")
+	}
+	p, err := providers.New("exec", inst, func(v interface{}) error {
+		v.(*Options).Command = []string{"true"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("providers.New: %v", err)
+	}
+	if c := p.(*Client); c.timeout != DefaultTimeout {
+		t.Errorf("expected default timeout, got %s", c.timeout)
+	}
+}
@@ -33,6 +33,32 @@ type response struct {
 	} `json:"error"`
 }
 
+// check returns the error fixer reports, with status 200, in r.
+func (r *response) check() error {
+	if !r.Success {
+		return fmt.Errorf("fixer: %s", r.Error.Info)
+	}
+	return nil
+}
+
+// checkResponse keeps error responses out of the HTTP cache.
+func checkResponse(body []byte) error {
+	var r response
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	return r.check()
+}
+
+func init() {
+	providers.Register("fixer", nil, func(inst providers.Instance, _ interface{}) (interface{}, error) {
+		if inst.Key == "" {
+			return nil, fmt.Errorf("missing API key")
+		}
+		return New(inst.Key, inst.Client, inst.Logger), nil
+	})
+}
+
 func New(apiKey string, client HTTPDoer, logger *log.Logger) *Client {
 	return &Client{apiKey: apiKey, client: client, logger: logger}
 }
@@ -44,9 +70,11 @@ func (c *Client) FetchCurrency(from, to string) (*providers.CurrencyData, error)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
+	req = providers.WithResponseCheck(req, checkResponse)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
+		err = providers.Redact(err, c.apiKey)
 		return nil, fmt.Errorf("fetch: %w", err)
 	}
 	defer resp.Body.Close()
@@ -57,8 +85,8 @@ func (c *Client) FetchCurrency(from, to string) (*providers.CurrencyData, error)
 	if err := json.Unmarshal(body, &r); err != nil {
 		return nil, fmt.Errorf("decode: %w", err)
 	}
-	if !r.Success {
-		return nil, fmt.Errorf("fixer: %s", r.Error.Info)
+	if err := r.check(); err != nil {
+		return nil, err
 	}
 
 	rate, ok := r.Rates[to]
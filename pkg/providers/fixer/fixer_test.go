@@ -118,6 +118,24 @@ func TestFetchCurrency(t *testing.T) {
 	}
 }
 
+func TestFetchCurrency_ResponseCheck(t *testing.T) {
+	var check func([]byte) error
+	client := newTestClient(func(req *http.Request) (*http.Response, error) {
+		check = providers.ResponseCheck(req)
+		return nil, errors.New("offline")
+	})
+	client.FetchCurrency("EUR", "USD")
+	if check == nil {
+		t.Fatal("expected the request to carry a response check")
+	}
+	if err := check([]byte(`{"success": false, "error": {"code": 104, "info": "monthly usage limit reached"}}`)); err == nil {
+		t.Error("expected an error response to fail the check")
+	}
+	if err := check([]byte(`{"success": true, "timestamp": 1666108800, "base": "EUR", "rates": {"USD": 1.05}}`)); err != nil {
+		t.Errorf("expected a rate to pass the check, got %v", err)
+	}
+}
+
 func TestNew(t *testing.T) {
 	logger := log.New(io.Discard, "Error")
 	client := New("secret", &http.Client{}, logger)
@@ -125,3 +143,18 @@ func TestNew(t *testing.T) {
 		t.Errorf("expected apiKey secret, got %s", client.apiKey)
 	}
 }
+
+func TestRegistered(t *testing.T) {
+	inst := providers.Instance{Name: "fx", Client: &http.Client{}, Logger: log.New(io.Discard, "Error")}
+	if _, err := providers.New("fixer", inst, nil); err == nil {
+		t.Error("expected error for missing key, got nil")
+	}
+	inst.Key = "secret"
+	p, err := providers.New("fixer", inst, nil)
+	if err != nil {
+		t.Fatalf("providers.New: %v", err)
+	}
+	if _, ok := p.(providers.CurrencyProvider); !ok {
+		t.Errorf("expected a currency provider, got %T", p)
+	}
+}
@@ -0,0 +1,245 @@
+// Package httpjson implements a provider that is defined entirely by
+// configuration: a URL template, optional headers and path expressions that
+// locate the price, date and currency in the JSON response.
+//
+// Path expressions follow a small JSONPath subset: $ is the document root,
+// .name selects an object member, ['name'] does the same for names with
+// special characters and [n] selects an array element, counting from the end
+// when n is negative. For example $.data[0].close.
+package httpjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+// DefaultDateFormat is the layout used by marketstack, which is also what
+// most EOD APIs return.
+const DefaultDateFormat = "2006-01-02T15:04:05-0700"
+
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Spec describes how to query a source and extract a quote from it. URL and
+// header values may reference {symbol} and {key}. Currency is either a path
+// expression or, when it does not start with $, a fixed ISO 4217 code.
+// DateFormat is a Go time layout or "unix" for epoch seconds. Spec doubles as
+// the provider options, with Key taken from the instance.
+type Spec struct {
+	URL        string            `yaml:"url"`
+	Key        string            `yaml:"-"`
+	Headers    map[string]string `yaml:"headers"`
+	Price      string            `yaml:"price"`
+	Date       string            `yaml:"date"`
+	DateFormat string            `yaml:"date_format"`
+	Currency   string            `yaml:"currency"`
+}
+
+type Client struct {
+	spec   Spec
+	client HTTPDoer
+	logger *log.Logger
+	now    func() time.Time
+}
+
+func init() {
+	providers.Register("httpjson",
+		func() interface{} { return &Spec{} },
+		func(inst providers.Instance, options interface{}) (interface{}, error) {
+			spec := *options.(*Spec)
+			if spec.URL == "" || spec.Price == "" {
+				return nil, fmt.Errorf("url and price are required")
+			}
+			spec.Key = inst.Key
+			return New(spec, inst.Client, inst.Logger), nil
+		})
+}
+
+func New(spec Spec, client HTTPDoer, logger *log.Logger) *Client {
+	if spec.DateFormat == "" {
+		spec.DateFormat = DefaultDateFormat
+	}
+	return &Client{spec: spec, client: client, logger: logger, now: time.Now}
+}
+
+func (c *Client) FetchStock(symbol string) (*providers.StockData, error) {
+	expand := strings.NewReplacer("{symbol}", url.QueryEscape(symbol), "{key}", url.QueryEscape(c.spec.Key))
+
+	req, err := http.NewRequest("GET", expand.Replace(c.spec.URL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for symbol %s: %w", symbol, err)
+	}
+	headers := strings.NewReplacer("{symbol}", symbol, "{key}", c.spec.Key)
+	for k, v := range c.spec.Headers {
+		req.Header.Set(k, headers.Replace(v))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		err = providers.Redact(err, c.spec.Key, url.QueryEscape(c.spec.Key))
+		c.logger.Error("Failed to execute HTTP request", "symbol", symbol, "error", err)
+		return nil, fmt.Errorf("failed to fetch data for symbol %s: %w", symbol, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.logger.Error("Received non-OK HTTP status", "status", resp.Status, "symbol", symbol)
+		return nil, fmt.Errorf("bad response status for symbol %s: %s", symbol, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for %s: %w", symbol, err)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode response for %s: %w", symbol, err)
+	}
+
+	sd := &providers.StockData{Symbol: symbol}
+	if sd.Close, err = c.price(doc); err != nil {
+		return nil, fmt.Errorf("price for %s: %w", symbol, err)
+	}
+	if sd.Date, err = c.date(doc); err != nil {
+		return nil, fmt.Errorf("date for %s: %w", symbol, err)
+	}
+	if sd.Currency, err = c.currency(doc); err != nil {
+		return nil, fmt.Errorf("currency for %s: %w", symbol, err)
+	}
+	return sd, nil
+}
+
+func (c *Client) price(doc interface{}) (float64, error) {
+	v, err := Lookup(doc, c.spec.Price)
+	if err != nil {
+		return 0, err
+	}
+	switch p := v.(type) {
+	case float64:
+		return p, nil
+	case string:
+		return strconv.ParseFloat(p, 64)
+	}
+	return 0, fmt.Errorf("%s is not a number", c.spec.Price)
+}
+
+func (c *Client) date(doc interface{}) (time.Time, error) {
+	if c.spec.Date == "" {
+		return c.now(), nil
+	}
+	v, err := Lookup(doc, c.spec.Date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if c.spec.DateFormat == "unix" {
+		switch d := v.(type) {
+		case float64:
+			return time.Unix(int64(d), 0), nil
+		case string:
+			n, err := strconv.ParseInt(d, 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(n, 0), nil
+		}
+		return time.Time{}, fmt.Errorf("%s is not a timestamp", c.spec.Date)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s is not a string", c.spec.Date)
+	}
+	return time.Parse(c.spec.DateFormat, s)
+}
+
+func (c *Client) currency(doc interface{}) (string, error) {
+	if !strings.HasPrefix(c.spec.Currency, "$") {
+		return c.spec.Currency, nil
+	}
+	v, err := Lookup(doc, c.spec.Currency)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s is not a string", c.spec.Currency)
+	}
+	return s, nil
+}
+
+// Lookup evaluates the path expression expr against a document decoded by
+// encoding/json.
+func Lookup(doc interface{}, expr string) (interface{}, error) {
+	rest := strings.TrimPrefix(expr, "$")
+	cur := doc
+	for rest != "" {
+		var key string
+		index, isIndex := 0, false
+
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("invalid path %q: empty member name", expr)
+			}
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", expr)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				key = inner[1 : len(inner)-1]
+				break
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", expr, inner)
+			}
+			index, isIndex = n, true
+		default:
+			// Allow a leading member name without the $. prefix.
+			rest = "." + rest
+			continue
+		}
+
+		if isIndex {
+			arr, ok := cur.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("path %q: not an array at [%d]", expr, index)
+			}
+			if index < 0 {
+				index += len(arr)
+			}
+			if index < 0 || index >= len(arr) {
+				return nil, fmt.Errorf("path %q: index %d out of range", expr, index)
+			}
+			cur = arr[index]
+			continue
+		}
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %q: not an object at %q", expr, key)
+		}
+		if cur, ok = obj[key]; !ok {
+			return nil, fmt.Errorf("path %q: no member %q", expr, key)
+		}
+	}
+	return cur, nil
+}
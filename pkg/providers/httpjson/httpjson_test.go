@@ -0,0 +1,144 @@
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+)
+
+type mockHTTPClient struct {
+	DoFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return m.DoFunc(req)
+}
+
+func newTestClient(spec Spec, status int, body string, seen *http.Request) *Client {
+	logger := log.New(io.Discard, "Error")
+	return New(spec, &mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		*seen = *req
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+		}, nil
+	}}, logger)
+}
+
+func TestFetchStock(t *testing.T) {
+	spec := Spec{
+		URL:      "https://example.com/quote?s={symbol}&token={key}",
+		Key:      "secret",
+		Headers:  map[string]string{"Authorization": "Bearer {key}"},
+		Price:    "$.data[0].close",
+		Date:     "$.data[0].date",
+		Currency: "$.meta['currency']",
+	}
+	body := `{"meta":{"currency":"USD"},"data":[{"date":"2025-08-18T00:00:00+0000","close":"150.75"}]}`
+
+	var req http.Request
+	sd, err := newTestClient(spec, http.StatusOK, body, &req).FetchStock("BRK B")
+	if err != nil {
+		t.Fatalf("FetchStock: %v", err)
+	}
+	if got, want := req.URL.String(), "https://example.com/quote?s=BRK+B&token=secret"; got != want {
+		t.Errorf("url = %q; want %q", got, want)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("unexpected Authorization header %q", got)
+	}
+	if sd.Symbol != "BRK B" || sd.Close != 150.75 || sd.Currency != "USD" {
+		t.Errorf("unexpected data: %+v", sd)
+	}
+	if !sd.Date.Equal(time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date mismatch: %v", sd.Date)
+	}
+}
+
+func TestFetchStock_UnixDateAndFixedCurrency(t *testing.T) {
+	spec := Spec{
+		URL:        "https://example.com/{symbol}",
+		Price:      "price",
+		Date:       "ts",
+		DateFormat: "unix",
+		Currency:   "EUR",
+	}
+	var req http.Request
+	sd, err := newTestClient(spec, http.StatusOK, `{"price":9.5,"ts":1666108800}`, &req).FetchStock("X")
+	if err != nil {
+		t.Fatalf("FetchStock: %v", err)
+	}
+	if sd.Close != 9.5 || sd.Currency != "EUR" || sd.Date.Unix() != 1666108800 {
+		t.Errorf("unexpected data: %+v", sd)
+	}
+}
+
+func TestFetchStock_Errors(t *testing.T) {
+	spec := Spec{URL: "https://example.com/{symbol}", Price: "$.price", Date: "$.date"}
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"http error", http.StatusNotFound, ""},
+		{"malformed json", http.StatusOK, `{"price":`},
+		{"missing price", http.StatusOK, `{"date":"2025-08-18T00:00:00+0000"}`},
+		{"price not a number", http.StatusOK, `{"price":true,"date":"2025-08-18T00:00:00+0000"}`},
+		{"bad date", http.StatusOK, `{"price":1,"date":"18/08/2025"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req http.Request
+			if _, err := newTestClient(spec, tt.status, tt.body, &req).FetchStock("X"); err == nil {
+				t.Error("expected an error but got none")
+			}
+		})
+	}
+
+	c := New(spec, &mockHTTPClient{DoFunc: func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("network down")
+	}}, log.New(io.Discard, "Error"))
+	if _, err := c.FetchStock("X"); err == nil {
+		t.Error("expected network error, got nil")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"a":{"b c":[1,2,{"d":"x"}]},"e":[10,20]}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr    string
+		want    interface{}
+		wantErr bool
+	}{
+		{expr: "$.a['b c'][2].d", want: "x"},
+		{expr: `a["b c"][0]`, want: 1.0},
+		{expr: "$.e[-1]", want: 20.0},
+		{expr: "$", want: doc},
+		{expr: "$.e[2]", wantErr: true},
+		{expr: "$.missing", wantErr: true},
+		{expr: "$.e.x", wantErr: true},
+		{expr: "$.a[0]", wantErr: true},
+		{expr: "$.e[x]", wantErr: true},
+		{expr: "$.e[0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Lookup(doc, tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Lookup(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lookup(%q) = %v; want %v", tt.expr, got, tt.want)
+		}
+	}
+}
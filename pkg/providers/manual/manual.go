@@ -0,0 +1,86 @@
+// Package manual implements a provider for prices maintained by hand, such as
+// the unit value of a private pension fund that no API publishes.
+package manual
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+// Price is a fixed price of a symbol at a date.
+type Price struct {
+	Symbol   string    `yaml:"symbol"`
+	Price    float64   `yaml:"price"`
+	Date     time.Time `yaml:"date"`
+	Currency string    `yaml:"currency"`
+}
+
+// Options configure a manual provider instance.
+type Options struct {
+	Prices []Price `yaml:"prices"`
+}
+
+// Validate checks that every price has a symbol, a positive price and a
+// date.
+func (o *Options) Validate() error {
+	for i, p := range o.Prices {
+		switch {
+		case p.Symbol == "":
+			return &providers.OptionError{Path: []interface{}{"prices", i}, Msg: "symbol is required"}
+		case p.Price <= 0:
+			return &providers.OptionError{Path: []interface{}{"prices", i, "price"}, Msg: "must be positive"}
+		case p.Date.IsZero():
+			return &providers.OptionError{Path: []interface{}{"prices", i}, Msg: "date is required"}
+		}
+	}
+	return nil
+}
+
+type Client struct {
+	prices  map[string]Price
+	symbols []string
+}
+
+func init() {
+	providers.Register("manual",
+		func() interface{} { return &Options{} },
+		func(_ providers.Instance, options interface{}) (interface{}, error) {
+			return New(options.(*Options).Prices), nil
+		})
+}
+
+func New(prices []Price) *Client {
+	c := &Client{prices: make(map[string]Price, len(prices))}
+	for _, p := range prices {
+		if _, ok := c.prices[p.Symbol]; !ok {
+			c.symbols = append(c.symbols, p.Symbol)
+		}
+		c.prices[p.Symbol] = p
+	}
+	return c
+}
+
+// Symbols returns the symbols with a configured price in configuration order.
+func (c *Client) Symbols() []string {
+	return c.symbols
+}
+
+// FixedPrices reports that manual prices are kept by hand.
+func (c *Client) FixedPrices() bool {
+	return true
+}
+
+func (c *Client) FetchStock(symbol string) (*providers.StockData, error) {
+	p, ok := c.prices[symbol]
+	if !ok {
+		return nil, fmt.Errorf("no manual price for symbol %s", symbol)
+	}
+	return &providers.StockData{
+		Symbol:   p.Symbol,
+		Date:     p.Date,
+		Close:    p.Price,
+		Currency: p.Currency,
+	}, nil
+}
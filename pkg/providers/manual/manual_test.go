@@ -0,0 +1,53 @@
+package manual
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchStock(t *testing.T) {
+	date := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	prices := []Price{
+		{Symbol: "PENSION", Price: 12.34, Date: date, Currency: "EUR"},
+		{Symbol: "ART", Price: 1000, Date: date},
+	}
+	c := New(prices)
+
+	sd, err := c.FetchStock("PENSION")
+	if err != nil {
+		t.Fatalf("FetchStock: %v", err)
+	}
+	if sd.Symbol != "PENSION" || sd.Close != 12.34 || !sd.Date.Equal(date) || sd.Currency != "EUR" {
+		t.Errorf("unexpected data: %+v", sd)
+	}
+
+	if _, err := c.FetchStock("UNKNOWN"); err == nil {
+		t.Error("expected an error for unknown symbol, got nil")
+	}
+
+	if got, want := c.Symbols(), []string{"PENSION", "ART"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Symbols() = %v; want %v", got, want)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	date := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		price Price
+		want  string
+	}{
+		{Price{Symbol: "PENSION", Price: 12.34, Date: date}, ""},
+		{Price{Symbol: "PENSION", Price: 12.34}, "prices[1]: date is required"},
+		{Price{Price: 12.34, Date: date}, "prices[1]: symbol is required"},
+		{Price{Symbol: "PENSION", Date: date}, "prices[1].price: must be positive"},
+	}
+	for _, tt := range tests {
+		o := Options{Prices: []Price{{Symbol: "ART", Price: 1000, Date: date}, tt.price}}
+		err := o.Validate()
+		if got := fmt.Sprint(err); tt.want == "" && err != nil || tt.want != "" && got != tt.want {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.price, err, tt.want)
+		}
+	}
+}
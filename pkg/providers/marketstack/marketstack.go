@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"git.sr.ht/~atmosx/calais/pkg/log"
@@ -41,11 +42,43 @@ func (mt *MarketstackTime) UnmarshalJSON(b []byte) error {
 
 type marketstackResponse struct {
 	Data []struct {
-		Symbol string          `json:"symbol"`
-		Date   MarketstackTime `json:"date"`
-		Close  float64         `json:"close"`
-		Volume float64         `json:"volume"`
+		Symbol        string          `json:"symbol"`
+		Date          MarketstackTime `json:"date"`
+		Close         float64         `json:"close"`
+		Volume        float64         `json:"volume"`
+		PriceCurrency string          `json:"price_currency"`
 	} `json:"data"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// check returns the error marketstack reports in r, which may come with
+// status 200.
+func (r *marketstackResponse) check() error {
+	if r.Error != nil {
+		return fmt.Errorf("marketstack: %s: %s", r.Error.Code, r.Error.Message)
+	}
+	return nil
+}
+
+// checkResponse keeps error responses out of the HTTP cache.
+func checkResponse(body []byte) error {
+	var r marketstackResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return err
+	}
+	return r.check()
+}
+
+func init() {
+	providers.Register("marketstack", nil, func(inst providers.Instance, _ interface{}) (interface{}, error) {
+		if inst.Key == "" {
+			return nil, fmt.Errorf("missing API key")
+		}
+		return New(inst.Key, inst.Client, inst.Logger), nil
+	})
 }
 
 func New(apiKey string, client HTTPDoer, logger *log.Logger) *Client {
@@ -64,9 +97,11 @@ func (c *Client) FetchStock(symbol string) (*providers.StockData, error) {
 		c.logger.Error("Failed to create HTTP request", "symbol", symbol, "error", err)
 		return nil, fmt.Errorf("failed to create request for symbol %s: %w", symbol, err)
 	}
+	req = providers.WithResponseCheck(req, checkResponse)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
+		err = providers.Redact(err, c.apiKey)
 		c.logger.Error("Failed to execute HTTP request", "symbol", symbol, "error", err)
 		return nil, fmt.Errorf("failed to fetch data for symbol %s: %w", symbol, err)
 	}
@@ -88,16 +123,26 @@ func (c *Client) FetchStock(symbol string) (*providers.StockData, error) {
 		c.logger.Error("Failed to unmarshal JSON response", "symbol", symbol, "error", err)
 		return nil, fmt.Errorf("failed to decode response for %s: %w", symbol, err)
 	}
+	if err := marketstackData.check(); err != nil {
+		return nil, err
+	}
 
 	if len(marketstackData.Data) == 0 {
 		return nil, fmt.Errorf("no data returned for symbol %s", symbol)
 	}
 
 	stock := marketstackData.Data[0]
+	// Currencies come in lower case, e.g. usd, but minor units such as GBp
+	// are case sensitive.
+	currency := stock.PriceCurrency
+	if !providers.IsMinorUnit(currency) {
+		currency = strings.ToUpper(currency)
+	}
 	return &providers.StockData{
-		Symbol: stock.Symbol,
-		Date:   time.Time(stock.Date),
-		Close:  stock.Close,
-		Volume: stock.Volume,
+		Symbol:   stock.Symbol,
+		Date:     time.Time(stock.Date),
+		Close:    stock.Close,
+		Volume:   stock.Volume,
+		Currency: currency,
 	}, nil
 }
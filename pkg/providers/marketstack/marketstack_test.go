@@ -5,7 +5,9 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -36,7 +38,7 @@ func TestFetchStock(t *testing.T) {
 		{
 			name: "success",
 			mockDoFunc: func(req *http.Request) (*http.Response, error) {
-				json := `{"data":[{"symbol":"AAPL","date":"2025-08-18T00:00:00+0000","close":150.75,"volume":12345678}]}`
+				json := `{"data":[{"symbol":"AAPL","date":"2025-08-18T00:00:00+0000","close":150.75,"volume":12345678,"price_currency":"usd"}]}`
 				return &http.Response{
 					StatusCode: http.StatusOK,
 					Body:       io.NopCloser(bytes.NewBufferString(json)),
@@ -47,7 +49,7 @@ func TestFetchStock(t *testing.T) {
 				if err != nil {
 					t.Fatalf("unexpected error: %v", err)
 				}
-				if sd.Symbol != "AAPL" || sd.Close != 150.75 {
+				if sd.Symbol != "AAPL" || sd.Close != 150.75 || sd.Currency != "USD" {
 					t.Errorf("unexpected data: %+v", sd)
 				}
 				if sd.Date.Year() != 2025 || sd.Date.Month() != time.August || sd.Date.Day() != 18 {
@@ -55,6 +57,40 @@ func TestFetchStock(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "minor unit",
+			mockDoFunc: func(req *http.Request) (*http.Response, error) {
+				json := `{"data":[{"symbol":"VOD.XLON","date":"2025-08-18T00:00:00+0000","close":72.15,"price_currency":"GBp"}]}`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(json)),
+				}, nil
+			},
+			check: func(t *testing.T, sd *providers.StockData, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if sd.Currency != "GBp" {
+					t.Errorf("expected the minor unit GBp, got %q", sd.Currency)
+				}
+			},
+		},
+		{
+			name: "api error",
+			mockDoFunc: func(req *http.Request) (*http.Response, error) {
+				json := `{"error":{"code":"usage_limit_reached","message":"Your monthly usage limit has been reached."}}`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(json)),
+				}, nil
+			},
+			expectError: true,
+			check: func(t *testing.T, sd *providers.StockData, err error) {
+				if !strings.Contains(err.Error(), "usage_limit_reached") {
+					t.Errorf("expected the API error, got %v", err)
+				}
+			},
+		},
 		{
 			name: "http error",
 			mockDoFunc: func(req *http.Request) (*http.Response, error) {
@@ -72,6 +108,18 @@ func TestFetchStock(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "network error does not leak the key",
+			mockDoFunc: func(req *http.Request) (*http.Response, error) {
+				return nil, &url.Error{Op: "Get", URL: req.URL.String(), Err: errors.New("timeout")}
+			},
+			expectError: true,
+			check: func(t *testing.T, sd *providers.StockData, err error) {
+				if strings.Contains(err.Error(), "test-key") {
+					t.Errorf("error leaks the API key: %v", err)
+				}
+			},
+		},
 		{
 			name: "malformed JSON",
 			mockDoFunc: func(req *http.Request) (*http.Response, error) {
@@ -111,6 +159,24 @@ func TestFetchStock(t *testing.T) {
 	}
 }
 
+func TestFetchStock_ResponseCheck(t *testing.T) {
+	var check func([]byte) error
+	client := newTestClient(func(req *http.Request) (*http.Response, error) {
+		check = providers.ResponseCheck(req)
+		return nil, errors.New("offline")
+	})
+	client.FetchStock("AAPL")
+	if check == nil {
+		t.Fatal("expected the request to carry a response check")
+	}
+	if err := check([]byte(`{"error":{"code":"rate_limit_reached","message":"Too many requests."}}`)); err == nil {
+		t.Error("expected an error response to fail the check")
+	}
+	if err := check([]byte(`{"data":[{"symbol":"AAPL","date":"2025-08-18T00:00:00+0000","close":150.75}]}`)); err != nil {
+		t.Errorf("expected a price to pass the check, got %v", err)
+	}
+}
+
 func TestNew(t *testing.T) {
 	logger := log.New(os.Stdout, "Info")
 	httpClient := &http.Client{}
@@ -128,3 +194,18 @@ func TestNew(t *testing.T) {
 		t.Error("logger not wired correctly")
 	}
 }
+
+func TestRegistered(t *testing.T) {
+	inst := providers.Instance{Name: "ms", Client: &http.Client{}, Logger: log.New(io.Discard, "Error")}
+	if _, err := providers.New("marketstack", inst, nil); err == nil {
+		t.Error("expected error for missing key, got nil")
+	}
+	inst.Key = "secret"
+	p, err := providers.New("marketstack", inst, nil)
+	if err != nil {
+		t.Fatalf("providers.New: %v", err)
+	}
+	if c, ok := p.(*Client); !ok || c.apiKey != "secret" {
+		t.Errorf("unexpected provider %#v", p)
+	}
+}
@@ -0,0 +1,68 @@
+package providers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseNumber reads a number such as 1,234.56, or 1.234,56 when decimal is
+// ','. The other separator groups thousands, as do spaces and apostrophes,
+// but only between groups of three digits before the decimal separator, so
+// that 150,75 is not misread as 15075 with a decimal point.
+func ParseNumber(s string, decimal rune) (float64, error) {
+	group := ','
+	if decimal == ',' {
+		group = '.'
+	}
+	whole, frac, found := strings.Cut(s, string(decimal))
+	sign := ""
+	if strings.HasPrefix(whole, "-") || strings.HasPrefix(whole, "+") {
+		sign, whole = whole[:1], whole[1:]
+	}
+	whole, ok := ungroup(whole, group)
+	if !ok || !isDigits(whole) || !isDigits(frac) || whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	digits := sign + whole
+	if found {
+		digits += "." + frac
+	}
+	n, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+// ungroup drops the group separators from the integer part of a number.
+// Separators are only accepted between groups of three digits, e.g.
+// 1,234,567.
+func ungroup(s string, group rune) (string, bool) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\'', '\u00a0', '\u202f':
+			return group
+		}
+		return r
+	}, s)
+	if !strings.ContainsRune(s, group) {
+		return s, true
+	}
+	groups := strings.Split(s, string(group))
+	for i, g := range groups {
+		if len(g) == 0 || len(g) > 3 || i > 0 && len(g) != 3 || !isDigits(g) {
+			return "", false
+		}
+	}
+	return strings.Join(groups, ""), true
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
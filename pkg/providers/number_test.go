@@ -0,0 +1,39 @@
+package providers
+
+import "testing"
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		decimal rune
+		want    float64
+		ok      bool
+	}{
+		{"237.88", '.', 237.88, true},
+		{"1,234,567.5", '.', 1234567.5, true},
+		{"1 234.5", '.', 1234.5, true},
+		{"1'234.5", '.', 1234.5, true},
+		{"-0.45", '.', -0.45, true},
+		{".5", '.', 0.5, true},
+		{"1.234,5", ',', 1234.5, true},
+		{"1 234,5", ',', 1234.5, true},
+		{"150,75", ',', 150.75, true},
+		{"150,75", '.', 0, false},
+		{"1,5", '.', 0, false},
+		{"1234,567", '.', 0, false},
+		{",123", '.', 0, false},
+		{"1,,234", '.', 0, false},
+		{"1.234.5", '.', 0, false},
+		{"1.234,5", '.', 0, false},
+		{"12.34 2025", '.', 0, false},
+		{"1e5", '.', 0, false},
+		{"Inf", '.', 0, false},
+		{"-", '.', 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseNumber(tt.in, tt.decimal)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseNumber(%q, %q) = %v, %v; want %v", tt.in, tt.decimal, got, err, tt.want)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package providers
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // StockData represents a single stock end-of-day record.
 type StockData struct {
-	Symbol string
-	Date   time.Time
-	Close  float64
-	Volume float64
+	Symbol   string
+	Date     time.Time
+	Close    float64
+	Volume   float64
+	Currency string // ISO 4217 code of the quote or a minor unit such as GBX, empty when unknown
 }
 
 // CurrencyData represents a single currency pair rate.
@@ -26,3 +30,38 @@ type StockProvider interface {
 type CurrencyProvider interface {
 	FetchCurrency(from, to string) (*CurrencyData, error)
 }
+
+// HistoricalStockProvider is a StockProvider that can also return end-of-day
+// records for a date range.
+type HistoricalStockProvider interface {
+	StockProvider
+	FetchStockRange(symbol string, from, to time.Time) ([]StockData, error)
+}
+
+// Redact hides secrets, such as API keys sent as query parameters, from the
+// message of err. Errors returned by http.Client include the request URL, so
+// providers pass them through Redact before logging or returning them.
+func Redact(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	for _, s := range secrets {
+		if s != "" {
+			msg = strings.ReplaceAll(msg, s, "REDACTED")
+		}
+	}
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
@@ -1,6 +1,9 @@
 package providers
 
 import (
+	"errors"
+	"math"
+	"strings"
 	"testing"
 	"time"
 )
@@ -28,3 +31,46 @@ func TestStockData(t *testing.T) {
 		t.Errorf("expected Volume to be 1000000, got '%f'", sd.Volume)
 	}
 }
+
+func TestRedact(t *testing.T) {
+	if Redact(nil, "secret") != nil {
+		t.Error("expected nil for nil error")
+	}
+	orig := errors.New(`Get "https://api.example.com/?access_key=secret": dial tcp: timeout`)
+	err := Redact(orig, "", "secret")
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("key not redacted: %v", err)
+	}
+	if !errors.Is(err, orig) {
+		t.Error("redacted error does not wrap the original")
+	}
+	plain := errors.New("no data")
+	if Redact(plain, "secret") != plain {
+		t.Error("expected errors without secrets to be returned as is")
+	}
+}
+
+func TestMajorUnit(t *testing.T) {
+	tests := []struct {
+		price    float64
+		currency string
+		want     float64
+		major    string
+	}{
+		{7215, "GBX", 72.15, "GBP"},
+		{7215, "GBp", 72.15, "GBP"},
+		{72.15, "GBP", 72.15, "GBP"},
+		{18250, "ZAc", 182.5, "ZAR"},
+		{1520, "ILA", 15.2, "ILS"},
+		{150, "", 150, ""},
+	}
+	for _, tt := range tests {
+		price, major := MajorUnit(tt.price, tt.currency)
+		if math.Abs(price-tt.want) > 1e-9 || major != tt.major {
+			t.Errorf("MajorUnit(%v, %q) = %v, %q, want %v, %q", tt.price, tt.currency, price, major, tt.want, tt.major)
+		}
+	}
+	if !IsMinorUnit("GBX") || IsMinorUnit("GBP") {
+		t.Error("unexpected IsMinorUnit result")
+	}
+}
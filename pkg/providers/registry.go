@@ -0,0 +1,175 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+)
+
+// HTTPDoer is the subset of *http.Client used by the HTTP based providers.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type responseCheckKey struct{}
+
+// WithResponseCheck returns req carrying check, which returns an error when
+// the body of a 200 response reports an error, as some APIs do for quota,
+// rate limit or authentication errors. Caching clients only keep responses
+// that pass it.
+func WithResponseCheck(req *http.Request, check func(body []byte) error) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), responseCheckKey{}, check))
+}
+
+// ResponseCheck returns the check req carries, or nil.
+func ResponseCheck(req *http.Request) func(body []byte) error {
+	check, _ := req.Context().Value(responseCheckKey{}).(func(body []byte) error)
+	return check
+}
+
+// Instance carries the settings shared by every configured provider.
+type Instance struct {
+	Name   string
+	Key    string
+	Client HTTPDoer
+	Logger *log.Logger
+}
+
+// Factory builds a provider from an instance and its decoded options. The
+// options value is the one returned by the registration's NewOptions, or nil
+// when the provider takes no options. The provider must implement
+// StockProvider, CurrencyProvider or both.
+type Factory func(inst Instance, options interface{}) (interface{}, error)
+
+// Registration describes a provider type.
+type Registration struct {
+	Type string
+	// NewOptions returns a pointer to the provider's options struct holding
+	// its defaults. It is nil for providers without options.
+	NewOptions func() interface{}
+	Factory    Factory
+}
+
+// SymbolLister is implemented by providers that know their own symbols, such
+// as manual prices. It is used when an instance lists no stocks.
+type SymbolLister interface {
+	Symbols() []string
+}
+
+// FixedPricer is implemented by providers whose prices are kept by hand, such
+// as manual prices. Their date is when the price was set rather than when it
+// was published, so they are never stale.
+type FixedPricer interface {
+	FixedPrices() bool
+}
+
+// Validator is implemented by options structs that check their values once
+// decoded. Errors about a single option should be *OptionError values, so
+// that the configuration can cite the option's position.
+type Validator interface {
+	Validate() error
+}
+
+// OptionError reports an invalid option. Path leads to it from the options
+// mapping by field name and list index, e.g. "prices", 0, "date".
+type OptionError struct {
+	Path []interface{}
+	Msg  string
+}
+
+func (e *OptionError) Error() string {
+	var b strings.Builder
+	for _, p := range e.Path {
+		switch p := p.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", p)
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			fmt.Fprint(&b, p)
+		}
+	}
+	return b.String() + ": " + e.Msg
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Registration{}
+)
+
+// Register makes a provider type available by name. It is meant to be called
+// from the provider package's init function and panics on duplicates.
+func Register(typ string, newOptions func() interface{}, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[typ]; ok {
+		panic("providers: Register called twice for " + typ)
+	}
+	registry[typ] = Registration{Type: typ, NewOptions: newOptions, Factory: factory}
+}
+
+// Lookup returns the registration of a provider type.
+func Lookup(typ string) (Registration, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	r, ok := registry[typ]
+	return r, ok
+}
+
+// Types returns the registered provider types in alphabetical order.
+func Types() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New builds a provider of type typ. decode fills the provider's options
+// struct from configuration; it may be nil when no options were given. Types
+// without options get an empty struct so that decode can reject any option.
+func New(typ string, inst Instance, decode func(v interface{}) error) (interface{}, error) {
+	r, ok := Lookup(typ)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider type %q", typ)
+	}
+
+	var options interface{}
+	if r.NewOptions != nil {
+		options = r.NewOptions()
+	}
+	if decode != nil {
+		target := options
+		if target == nil {
+			target = &struct{}{}
+		}
+		if err := decode(target); err != nil {
+			return nil, fmt.Errorf("provider %s: options: %w", inst.Name, err)
+		}
+	}
+	if v, ok := options.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("provider %s: options: %w", inst.Name, err)
+		}
+	}
+
+	p, err := r.Factory(inst, options)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: %w", inst.Name, err)
+	}
+	_, isStock := p.(StockProvider)
+	_, isCurrency := p.(CurrencyProvider)
+	if !isStock && !isCurrency {
+		return nil, fmt.Errorf("provider %s: type %s provides neither stocks nor currencies", inst.Name, typ)
+	}
+	return p, nil
+}
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+)
+
+type testOptions struct {
+	Value string
+}
+
+type testStockProvider struct {
+	inst    Instance
+	options *testOptions
+}
+
+func (p *testStockProvider) FetchStock(symbol string) (*StockData, error) {
+	return &StockData{Symbol: symbol}, nil
+}
+
+func init() {
+	Register("test-stock",
+		func() interface{} { return &testOptions{Value: "default"} },
+		func(inst Instance, options interface{}) (interface{}, error) {
+			return &testStockProvider{inst: inst, options: options.(*testOptions)}, nil
+		})
+	Register("test-nothing", nil, func(Instance, interface{}) (interface{}, error) {
+		return struct{}{}, nil
+	})
+}
+
+func TestNew(t *testing.T) {
+	p, err := New("test-stock", Instance{Name: "one", Key: "k"}, func(v interface{}) error {
+		v.(*testOptions).Value = "configured"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	sp, ok := p.(*testStockProvider)
+	if !ok {
+		t.Fatalf("unexpected provider %T", p)
+	}
+	if sp.inst.Name != "one" || sp.inst.Key != "k" || sp.options.Value != "configured" {
+		t.Errorf("unexpected provider: %+v", sp)
+	}
+
+	p, err = New("test-stock", Instance{Name: "two"}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := p.(*testStockProvider).options.Value; got != "default" {
+		t.Errorf("expected default options, got %q", got)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	if _, err := New("missing", Instance{}, nil); err == nil {
+		t.Error("expected error for unknown type, got nil")
+	}
+	if _, err := New("test-nothing", Instance{}, nil); err == nil {
+		t.Error("expected error for provider without capabilities, got nil")
+	}
+	decodeErr := errors.New("bad options")
+	_, err := New("test-stock", Instance{}, func(interface{}) error { return decodeErr })
+	if !errors.Is(err, decodeErr) {
+		t.Errorf("expected decode error, got %v", err)
+	}
+	// Types without options still let decode reject unknown ones.
+	_, err = New("test-nothing", Instance{}, func(interface{}) error { return decodeErr })
+	if !errors.Is(err, decodeErr) {
+		t.Errorf("expected decode error for a type without options, got %v", err)
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	Register("test-stock", nil, nil)
+}
+
+func TestTypes(t *testing.T) {
+	types := Types()
+	var found int
+	for i, typ := range types {
+		if typ == "test-stock" || typ == "test-nothing" {
+			found++
+		}
+		if i > 0 && types[i-1] > typ {
+			t.Errorf("types not sorted: %v", types)
+		}
+	}
+	if found != 2 {
+		t.Errorf("expected test types in %v", types)
+	}
+}
@@ -0,0 +1,187 @@
+// Package scrape implements a provider that extracts a price and its date
+// from an HTML page using CSS selectors. It covers sources, such as mutual
+// fund NAV pages, that publish prices only as HTML.
+//
+// Numbers and dates are read according to a configurable locale: Decimal is
+// the decimal separator ("." or ",", the other one being treated as the
+// thousands separator) and DateFormat is a Go time layout.
+package scrape
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+const (
+	DefaultDecimal    = "."
+	DefaultDateFormat = "02/01/2006"
+)
+
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Spec describes where a page lives and how to read it. URL may reference
+// {symbol}. When Date is empty the fetch time is used. Spec doubles as the
+// provider options.
+type Spec struct {
+	URL        string `yaml:"url"`
+	Price      string `yaml:"price"`
+	Date       string `yaml:"date"`
+	DateFormat string `yaml:"date_format"`
+	Decimal    string `yaml:"decimal"`
+	Currency   string `yaml:"currency"`
+}
+
+type Client struct {
+	spec   Spec
+	price  cascadia.Sel
+	date   cascadia.Sel
+	client HTTPDoer
+	logger *log.Logger
+	now    func() time.Time
+}
+
+func init() {
+	providers.Register("scrape",
+		func() interface{} { return &Spec{} },
+		func(inst providers.Instance, options interface{}) (interface{}, error) {
+			spec := options.(*Spec)
+			if spec.URL == "" || spec.Price == "" {
+				return nil, fmt.Errorf("url and price are required")
+			}
+			return New(*spec, inst.Client, inst.Logger)
+		})
+}
+
+// New returns a client for spec. It fails when a selector does not compile or
+// the decimal separator is not supported.
+func New(spec Spec, client HTTPDoer, logger *log.Logger) (*Client, error) {
+	if spec.DateFormat == "" {
+		spec.DateFormat = DefaultDateFormat
+	}
+	if spec.Decimal == "" {
+		spec.Decimal = DefaultDecimal
+	}
+	if spec.Decimal != "." && spec.Decimal != "," {
+		return nil, fmt.Errorf("unsupported decimal separator %q", spec.Decimal)
+	}
+
+	c := &Client{spec: spec, client: client, logger: logger, now: time.Now}
+	var err error
+	if c.price, err = cascadia.Parse(spec.Price); err != nil {
+		return nil, fmt.Errorf("price selector %q: %w", spec.Price, err)
+	}
+	if spec.Date != "" {
+		if c.date, err = cascadia.Parse(spec.Date); err != nil {
+			return nil, fmt.Errorf("date selector %q: %w", spec.Date, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) FetchStock(symbol string) (*providers.StockData, error) {
+	u := strings.ReplaceAll(c.spec.URL, "{symbol}", url.QueryEscape(symbol))
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for symbol %s: %w", symbol, err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.logger.Error("Failed to execute HTTP request", "symbol", symbol, "error", err)
+		return nil, fmt.Errorf("failed to fetch data for symbol %s: %w", symbol, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.logger.Error("Received non-OK HTTP status", "status", resp.Status, "symbol", symbol)
+		return nil, fmt.Errorf("bad response status for symbol %s: %s", symbol, resp.Status)
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page for %s: %w", symbol, err)
+	}
+
+	sd := &providers.StockData{Symbol: symbol, Currency: c.spec.Currency}
+
+	node := cascadia.Query(doc, c.price)
+	if node == nil {
+		return nil, fmt.Errorf("price selector %q matched nothing for %s", c.spec.Price, symbol)
+	}
+	if sd.Close, err = ParseNumber(text(node), c.spec.Decimal); err != nil {
+		return nil, fmt.Errorf("price for %s: %w", symbol, err)
+	}
+
+	if c.date == nil {
+		sd.Date = c.now()
+		return sd, nil
+	}
+	node = cascadia.Query(doc, c.date)
+	if node == nil {
+		return nil, fmt.Errorf("date selector %q matched nothing for %s", c.spec.Date, symbol)
+	}
+	if sd.Date, err = ParseDate(text(node), c.spec.DateFormat); err != nil {
+		return nil, fmt.Errorf("date for %s: %w", symbol, err)
+	}
+	return sd, nil
+}
+
+// ParseNumber reads a localized number such as "1.234,56 €" using decimal as
+// the decimal separator. Text around the number, such as a currency, is
+// ignored, but text within it is not: "12,34 EUR 2025" is not a number.
+func ParseNumber(s, decimal string) (float64, error) {
+	sep, _ := utf8.DecodeRuneInString(decimal)
+	number := strings.TrimFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '-' && r != '+' && r != sep
+	})
+	n, err := providers.ParseNumber(number, sep)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", strings.TrimSpace(s))
+	}
+	return n, nil
+}
+
+// ParseDate reads a date formatted with layout. The date may be surrounded by
+// other words, as in "NAV at 18/09/2025".
+func ParseDate(s, layout string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(layout, s); err == nil {
+		return t, nil
+	}
+	for _, f := range strings.Fields(s) {
+		f = strings.TrimFunc(f, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if t, err := time.Parse(layout, f); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no date matching %q in %q", layout, s)
+}
+
+func text(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,179 @@
+package scrape
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+)
+
+type mockHTTPClient struct {
+	DoFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return m.DoFunc(req)
+}
+
+func fixture(t *testing.T, name string) func(req *http.Request) (*http.Response, error) {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(b))}, nil
+	}
+}
+
+func newTestClient(t *testing.T, spec Spec, fn func(req *http.Request) (*http.Response, error)) *Client {
+	t.Helper()
+	c, err := New(spec, &mockHTTPClient{DoFunc: fn}, log.New(io.Discard, "Error"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+func TestFetchStock_GreekLocale(t *testing.T) {
+	var url string
+	fn := fixture(t, "fund.html")
+	c := newTestClient(t, Spec{
+		URL:      "https://funds.example.gr/nav?code={symbol}",
+		Price:    "#nav tr.latest td.price",
+		Date:     "#nav tr.latest td.date",
+		Decimal:  ",",
+		Currency: "EUR",
+	}, func(req *http.Request) (*http.Response, error) {
+		url = req.URL.String()
+		return fn(req)
+	})
+
+	sd, err := c.FetchStock("GR_FUND1")
+	if err != nil {
+		t.Fatalf("FetchStock: %v", err)
+	}
+	if url != "https://funds.example.gr/nav?code=GR_FUND1" {
+		t.Errorf("unexpected url %s", url)
+	}
+	if sd.Symbol != "GR_FUND1" || sd.Close != 1234.5678 || sd.Currency != "EUR" {
+		t.Errorf("unexpected data: %+v", sd)
+	}
+	if !sd.Date.Equal(time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date mismatch: %v", sd.Date)
+	}
+}
+
+func TestFetchStock_DotDecimal(t *testing.T) {
+	c := newTestClient(t, Spec{
+		URL:        "https://example.com/{symbol}",
+		Price:      `section[data-field="nav"] .value`,
+		Date:       "section time",
+		DateFormat: "2006-01-02",
+	}, fixture(t, "nav_us.html"))
+
+	sd, err := c.FetchStock("FUND")
+	if err != nil {
+		t.Fatalf("FetchStock: %v", err)
+	}
+	if sd.Close != 12345.67 || sd.Date.Day() != 18 {
+		t.Errorf("unexpected data: %+v", sd)
+	}
+}
+
+func TestFetchStock_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		fn   func(req *http.Request) (*http.Response, error)
+	}{
+		{
+			name: "price selector matches nothing",
+			spec: Spec{Price: ".missing", Decimal: ","},
+			fn:   fixture(t, "fund.html"),
+		},
+		{
+			name: "date selector matches nothing",
+			spec: Spec{Price: "td.price", Date: ".missing", Decimal: ","},
+			fn:   fixture(t, "fund.html"),
+		},
+		{
+			name: "date in wrong format",
+			spec: Spec{Price: "td.price", Date: "td.date", DateFormat: "2006-01-02", Decimal: ","},
+			fn:   fixture(t, "fund.html"),
+		},
+		{
+			name: "price is not a number",
+			spec: Spec{Price: "h1", Decimal: ","},
+			fn:   fixture(t, "fund.html"),
+		},
+		{
+			name: "http error",
+			spec: Spec{Price: "td.price"},
+			fn: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+			},
+		},
+		{
+			name: "network error",
+			spec: Spec{Price: "td.price"},
+			fn: func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("network down")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTestClient(t, tt.spec, tt.fn).FetchStock("X"); err == nil {
+				t.Error("expected an error but got none")
+			}
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	logger := log.New(io.Discard, "Error")
+	if _, err := New(Spec{Price: "td[", Decimal: "."}, http.DefaultClient, logger); err == nil {
+		t.Error("expected selector error, got nil")
+	}
+	if _, err := New(Spec{Price: "td", Decimal: ";"}, http.DefaultClient, logger); err == nil {
+		t.Error("expected decimal separator error, got nil")
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		decimal string
+		want    float64
+	}{
+		{"1.234,56 €", ",", 1234.56},
+		{"-0,45", ",", -0.45},
+		{"12,345.67", ".", 12345.67},
+		{"1 234.5", ".", 1234.5},
+		{"NAV: 98.50 USD", ".", 98.5},
+		{"+0,45%", ",", 0.45},
+	}
+	for _, tt := range tests {
+		got, err := ParseNumber(tt.in, tt.decimal)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseNumber(%q, %q) = %v, %v; want %v", tt.in, tt.decimal, got, err, tt.want)
+		}
+	}
+
+	// Misplaced separators and text within the number are not dropped.
+	for _, in := range []string{"150,75", "12,34 EUR 2025", "1,2345.6", "NAV 2025: 98.50", "n/a"} {
+		if got, err := ParseNumber(in, "."); err == nil {
+			t.Errorf("ParseNumber(%q, \".\") = %v, expected an error", in, got)
+		}
+	}
+	if got, err := ParseNumber("12,34 EUR 2025", ","); err == nil {
+		t.Errorf("ParseNumber(\"12,34 EUR 2025\", \",\") = %v, expected an error", got)
+	}
+}
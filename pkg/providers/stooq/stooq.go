@@ -0,0 +1,203 @@
+package stooq
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+const (
+	quoteURL   = "https://stooq.com/q/l/"
+	historyURL = "https://stooq.com/q/d/l/"
+	dateLayout = "2006-01-02"
+)
+
+// suffixes maps marketstack-style exchange suffixes to the ones stooq uses.
+// Symbols without a suffix are assumed to be US listings.
+var suffixes = map[string]string{
+	"":      "us",
+	"US":    "us",
+	"DE":    "de",
+	"F":     "de",
+	"XETRA": "de",
+	"L":     "uk",
+	"LON":   "uk",
+	"XLON":  "uk",
+	"UK":    "uk",
+	"T":     "jp",
+	"JP":    "jp",
+	"HK":    "hk",
+	"WA":    "pl",
+	"PL":    "pl",
+	"BD":    "hu",
+	"HU":    "hu",
+}
+
+// currencies maps stooq markets to the currency they quote in. London quotes
+// are in pence.
+var currencies = map[string]string{
+	"us": "USD",
+	"de": "EUR",
+	"uk": "GBX",
+	"jp": "JPY",
+	"hk": "HKD",
+	"pl": "PLN",
+	"hu": "HUF",
+}
+
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	client HTTPDoer
+	logger *log.Logger
+}
+
+func init() {
+	providers.Register("stooq", nil, func(inst providers.Instance, _ interface{}) (interface{}, error) {
+		return New(inst.Client, inst.Logger), nil
+	})
+}
+
+func New(client HTTPDoer, logger *log.Logger) *Client {
+	return &Client{client: client, logger: logger}
+}
+
+// Symbol translates a marketstack-style ticker (e.g. SXR8.DE) to the stooq
+// form (sxr8.de). Unknown suffixes are passed through lowercased.
+func Symbol(symbol string) string {
+	base, suffix := symbol, ""
+	if i := strings.LastIndex(symbol, "."); i > 0 {
+		base, suffix = symbol[:i], symbol[i+1:]
+	}
+	if s, ok := suffixes[strings.ToUpper(suffix)]; ok {
+		return strings.ToLower(base) + "." + s
+	}
+	return strings.ToLower(symbol)
+}
+
+// Currency returns the currency stooq quotes symbol in, or "" for markets it
+// does not know.
+func Currency(symbol string) string {
+	s := Symbol(symbol)
+	return currencies[s[strings.LastIndex(s, ".")+1:]]
+}
+
+// FetchStock returns the latest end-of-day quote for symbol.
+func (c *Client) FetchStock(symbol string) (*providers.StockData, error) {
+	url := fmt.Sprintf("%s?s=%s&f=sd2t2ohlcv&h&e=csv", quoteURL, Symbol(symbol))
+
+	rows, err := c.get(url, symbol)
+	if err != nil {
+		return nil, err
+	}
+	// Symbol,Date,Time,Open,High,Low,Close,Volume
+	if len(rows) < 2 || len(rows[1]) < 8 {
+		return nil, fmt.Errorf("no data returned for symbol %s", symbol)
+	}
+	row := rows[1]
+	if row[1] == "N/D" {
+		return nil, fmt.Errorf("no data returned for symbol %s", symbol)
+	}
+	return parseRow(symbol, row[1], row[6], row[7])
+}
+
+// FetchStockRange returns the end-of-day quotes for symbol between from and
+// to, both inclusive, ordered by date.
+func (c *Client) FetchStockRange(symbol string, from, to time.Time) ([]providers.StockData, error) {
+	url := fmt.Sprintf("%s?s=%s&i=d&d1=%s&d2=%s", historyURL, Symbol(symbol),
+		from.Format("20060102"), to.Format("20060102"))
+
+	rows, err := c.get(url, symbol)
+	if err != nil {
+		return nil, err
+	}
+	// Date,Open,High,Low,Close,Volume
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("no data returned for symbol %s", symbol)
+	}
+	data := make([]providers.StockData, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		if len(row) < 5 {
+			continue
+		}
+		volume := ""
+		if len(row) > 5 {
+			volume = row[5]
+		}
+		sd, err := parseRow(symbol, row[0], row[4], volume)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, *sd)
+	}
+	return data, nil
+}
+
+func (c *Client) get(url, symbol string) ([][]string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for symbol %s: %w", symbol, err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.logger.Error("Failed to execute HTTP request", "symbol", symbol, "error", err)
+		return nil, fmt.Errorf("failed to fetch data for symbol %s: %w", symbol, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.logger.Error("Received non-OK HTTP status", "status", resp.Status, "symbol", symbol)
+		return nil, fmt.Errorf("bad response status for symbol %s: %s", symbol, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for %s: %w", symbol, err)
+	}
+	// Unknown symbols and empty ranges are answered with a plain text body.
+	if !strings.Contains(string(body), ",") {
+		return nil, fmt.Errorf("no data returned for symbol %s", symbol)
+	}
+
+	r := csv.NewReader(strings.NewReader(string(body)))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response for %s: %w", symbol, err)
+	}
+	return rows, nil
+}
+
+func parseRow(symbol, date, closePrice, volume string) (*providers.StockData, error) {
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q for %s: %w", date, symbol, err)
+	}
+	price, err := strconv.ParseFloat(closePrice, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid close %q for %s: %w", closePrice, symbol, err)
+	}
+	var vol float64
+	if volume != "" {
+		if vol, err = strconv.ParseFloat(volume, 64); err != nil {
+			return nil, fmt.Errorf("invalid volume %q for %s: %w", volume, symbol, err)
+		}
+	}
+	return &providers.StockData{
+		Symbol:   symbol,
+		Date:     t,
+		Close:    price,
+		Volume:   vol,
+		Currency: Currency(symbol),
+	}, nil
+}
@@ -0,0 +1,174 @@
+package stooq
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~atmosx/calais/pkg/log"
+	"git.sr.ht/~atmosx/calais/pkg/providers"
+)
+
+type mockHTTPClient struct {
+	DoFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return m.DoFunc(req)
+}
+
+func respond(status int, body string) func(req *http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+		}, nil
+	}
+}
+
+func newTestClient(fn func(req *http.Request) (*http.Response, error)) *Client {
+	logger := log.New(io.Discard, "Error")
+	return New(&mockHTTPClient{DoFunc: fn}, logger)
+}
+
+func TestSymbol(t *testing.T) {
+	tests := map[string]string{
+		"AAPL":       "aapl.us",
+		"SXR8.DE":    "sxr8.de",
+		"VOD.XLON":   "vod.uk",
+		"VOD.L":      "vod.uk",
+		"7203.T":     "7203.jp",
+		"TITC.AT":    "titc.at",
+		"BRK.B.US":   "brk.b.us",
+		"msft.us":    "msft.us",
+		"CDR.WA":     "cdr.pl",
+		"SXR8.XETRA": "sxr8.de",
+	}
+	for in, want := range tests {
+		if got := Symbol(in); got != want {
+			t.Errorf("Symbol(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestFetchStock(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockDoFunc  func(req *http.Request) (*http.Response, error)
+		expectError bool
+	}{
+		{
+			name: "success",
+			mockDoFunc: respond(http.StatusOK,
+				"Symbol,Date,Time,Open,High,Low,Close,Volume\r\nSXR8.DE,2025-09-17,17:36:00,590.1,596.0,589.5,595.22,12345\r\n"),
+		},
+		{
+			name: "no data",
+			mockDoFunc: respond(http.StatusOK,
+				"Symbol,Date,Time,Open,High,Low,Close,Volume\r\nXXX.DE,N/D,N/D,N/D,N/D,N/D,N/D,N/D\r\n"),
+			expectError: true,
+		},
+		{
+			name:        "http error",
+			mockDoFunc:  respond(http.StatusInternalServerError, ""),
+			expectError: true,
+		},
+		{
+			name: "network error",
+			mockDoFunc: func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("simulated network failure")
+			},
+			expectError: true,
+		},
+		{
+			name: "bad close",
+			mockDoFunc: respond(http.StatusOK,
+				"Symbol,Date,Time,Open,High,Low,Close,Volume\r\nSXR8.DE,2025-09-17,17:36:00,1,1,1,abc,1\r\n"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var url string
+			client := newTestClient(func(req *http.Request) (*http.Response, error) {
+				url = req.URL.String()
+				return tt.mockDoFunc(req)
+			})
+			sd, err := client.FetchStock("SXR8.DE")
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("did not expect an error: %v", err)
+			}
+			if !strings.Contains(url, "s=sxr8.de") {
+				t.Errorf("expected stooq symbol in url, got %s", url)
+			}
+			want := providers.StockData{
+				Symbol:   "SXR8.DE",
+				Date:     time.Date(2025, 9, 17, 0, 0, 0, 0, time.UTC),
+				Close:    595.22,
+				Volume:   12345,
+				Currency: "EUR",
+			}
+			if *sd != want {
+				t.Errorf("unexpected data: %+v", sd)
+			}
+		})
+	}
+}
+
+func TestFetchStock_Currency(t *testing.T) {
+	client := newTestClient(respond(http.StatusOK,
+		"Symbol,Date,Time,Open,High,Low,Close,Volume\r\nVOD.UK,2025-09-17,17:36:00,72,73,71,72.15,100\r\n"))
+	for symbol, want := range map[string]string{"VOD.L": "GBX", "VOD.UK": "GBX", "7203.T": "JPY", "TITC.AT": ""} {
+		sd, err := client.FetchStock(symbol)
+		if err != nil {
+			t.Fatalf("FetchStock(%q): %v", symbol, err)
+		}
+		if sd.Currency != want {
+			t.Errorf("FetchStock(%q) currency = %q, want %q", symbol, sd.Currency, want)
+		}
+	}
+}
+
+func TestFetchStockRange(t *testing.T) {
+	var url string
+	client := newTestClient(func(req *http.Request) (*http.Response, error) {
+		url = req.URL.String()
+		return respond(http.StatusOK,
+			"Date,Open,High,Low,Close,Volume\r\n2025-09-15,1,1,1,10.5,100\r\n2025-09-16,1,1,1,11.25,200\r\n")(req)
+	})
+
+	from := time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 9, 16, 0, 0, 0, 0, time.UTC)
+	data, err := client.FetchStockRange("AAPL", from, to)
+	if err != nil {
+		t.Fatalf("FetchStockRange: %v", err)
+	}
+	if !strings.Contains(url, "s=aapl.us") || !strings.Contains(url, "d1=20250915") || !strings.Contains(url, "d2=20250916") {
+		t.Errorf("unexpected url %s", url)
+	}
+	if len(data) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(data))
+	}
+	if data[1].Symbol != "AAPL" || data[1].Close != 11.25 || !data[1].Date.Equal(to) || data[1].Currency != "USD" {
+		t.Errorf("unexpected record: %+v", data[1])
+	}
+}
+
+func TestFetchStockRange_NoData(t *testing.T) {
+	client := newTestClient(respond(http.StatusOK, "No data"))
+	now := time.Now()
+	if _, err := client.FetchStockRange("AAPL", now, now); err == nil {
+		t.Error("expected an error but got none")
+	}
+}